@@ -0,0 +1,606 @@
+package api_test
+
+import (
+	"api_gateway/api/models"
+	"api_gateway/config"
+	"api_gateway/genproto/user_service"
+	"api_gateway/pkg/devbackend"
+	"api_gateway/pkg/jwt"
+	"api_gateway/pkg/ratelimit"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAdminLogin(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.loginSuperadmin()
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("tokens = %+v", tokens)
+	}
+	if me := s.me(tokens.AccessToken); me.UserRole != superadmin {
+		t.Fatalf("role = %q, want %q", me.UserRole, superadmin)
+	}
+
+	expectError(t, s.do(http.MethodPost, "/v1/admin/login", "", map[string]string{
+		"user_login":    devbackend.SuperadminLogin,
+		"user_password": "wrong",
+	}), http.StatusUnauthorized, "wrong login or password")
+
+	if p := expectError(t, s.do(http.MethodPost, "/v1/admin/login", "", "{"),
+		http.StatusBadRequest, "unexpected EOF"); p.Code != "INVALID_JSON" {
+		t.Fatalf("code = %q, want INVALID_JSON", p.Code)
+	}
+}
+
+func TestUserRegisterAndLogin(t *testing.T) {
+	s := newTestServer(t)
+	mail := newMail()
+
+	confirm := func(otp, email string) map[string]interface{} {
+		return map[string]interface{}{
+			"mail": mail,
+			"otp":  otp,
+			"User": []map[string]string{{
+				"email":         email,
+				"phone":         "+998901234567",
+				"fullname":      "E2E User",
+				"user_password": "Passw0rd!",
+			}},
+		}
+	}
+
+	expectError(t, s.do(http.MethodPost, "/v1/user/register-confirm", "", confirm(devbackend.OTP, mail)),
+		http.StatusBadRequest, "no pending registration for "+mail)
+
+	expect(t, s.do(http.MethodPost, "/v1/user/register", "", map[string]string{"mail": mail}), http.StatusOK, nil)
+
+	expectError(t, s.do(http.MethodPost, "/v1/user/register-confirm", "", confirm(devbackend.OTP, "someone@mail.ru")),
+		http.StatusBadRequest, "wrong gmail")
+	expectError(t, s.do(http.MethodPost, "/v1/user/register-confirm", "", confirm("000000", mail)),
+		http.StatusBadRequest, "wrong otp")
+
+	var tokens models.TokenResponse
+	expect(t, s.do(http.MethodPost, "/v1/user/register-confirm", "", confirm(devbackend.OTP, mail)), http.StatusOK, &tokens)
+	if me := s.me(tokens.AccessToken); me.UserRole != user {
+		t.Fatalf("role = %q, want %q", me.UserRole, user)
+	}
+
+	expectError(t, s.do(http.MethodPost, "/v1/user/register", "", map[string]string{"mail": mail}),
+		http.StatusConflict, mail+" already exists")
+
+	expect(t, s.do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"user_login":    mail,
+		"user_password": "Passw0rd!",
+	}), http.StatusOK, &tokens)
+	if tokens.AccessToken == "" {
+		t.Fatal("no access token")
+	}
+}
+
+func TestAdminRegister(t *testing.T) {
+	s := newTestServer(t)
+	mail := newMail()
+
+	superToken := s.loginSuperadmin().AccessToken
+
+	// admins may act on resources of other users, anyone could grant that to themselves
+	expectError(t, s.do(http.MethodPost, "/v1/admin/register", "", map[string]string{"mail": mail}),
+		http.StatusUnauthorized, "authorization header is missing")
+	expectError(t, s.do(http.MethodPost, "/v1/admin/register", s.registerUser().Tokens.AccessToken, map[string]string{"mail": mail}),
+		http.StatusForbidden, "Forbidden")
+
+	expect(t, s.do(http.MethodPost, "/v1/admin/register", superToken, map[string]string{"mail": mail}), http.StatusOK, nil)
+
+	var tokens models.TokenResponse
+	expect(t, s.do(http.MethodPost, "/v1/admin/register-confirm", superToken, map[string]interface{}{
+		"mail": mail,
+		"otp":  devbackend.OTP,
+		"Admin": []map[string]string{{
+			"email":         mail,
+			"phone":         "+998901234567",
+			"fullname":      "E2E Admin",
+			"user_password": "Passw0rd!",
+		}},
+	}), http.StatusOK, &tokens)
+
+	if me := s.me(tokens.AccessToken); me.UserRole != admin {
+		t.Fatalf("role = %q, want %q", me.UserRole, admin)
+	}
+}
+
+func TestRegisterConfirmWithoutAccount(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, tc := range []struct {
+		path, field, token string
+	}{
+		{"/v1/user/register-confirm", "User", ""},
+		{"/v1/admin/register-confirm", "Admin", s.loginSuperadmin().AccessToken},
+	} {
+		detail := "no " + strings.ToLower(tc.field) + " to register"
+		for _, accounts := range []interface{}{nil, []interface{}{}, []interface{}{nil}} {
+			p := expectError(t, s.do(http.MethodPost, tc.path, tc.token, map[string]interface{}{
+				"mail":   newMail(),
+				"otp":    devbackend.OTP,
+				tc.field: accounts,
+			}), http.StatusBadRequest, detail)
+			if len(p.Violations) != 1 || p.Violations[0].Field != tc.field {
+				t.Fatalf("%s with %s %v: violations = %+v", tc.path, tc.field, accounts, p.Violations)
+			}
+		}
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	s := newTestServer(t)
+	u := s.registerUser()
+
+	var fresh models.TokenResponse
+	expect(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": u.Tokens.RefreshToken}),
+		http.StatusOK, &fresh)
+	if me := s.me(fresh.AccessToken); me.UserID != u.ID {
+		t.Fatalf("user = %q, want %q", me.UserID, u.ID)
+	}
+
+	expectError(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": u.Tokens.RefreshToken}),
+		http.StatusUnauthorized, "refresh token has been revoked")
+	expectError(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": fresh.AccessToken}),
+		http.StatusUnauthorized, "refresh token expected")
+
+	p := expectError(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{}),
+		http.StatusBadRequest, "invalid request body")
+	if len(p.Violations) != 1 || p.Violations[0].Field != "RefreshToken" {
+		t.Fatalf("violations = %+v, want RefreshToken", p.Violations)
+	}
+}
+
+func TestRefreshTokenOfDeletedAccount(t *testing.T) {
+	s := newTestServer(t)
+	superadminToken := s.loginSuperadmin().AccessToken
+
+	u := s.registerUser()
+	expect(t, s.do(http.MethodDelete, "/v1/user/delete/"+u.ID, superadminToken, nil), http.StatusOK, nil)
+	expectError(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": u.Tokens.RefreshToken}),
+		http.StatusUnauthorized, "account no longer exists")
+
+	a := s.createAdmin()
+	expect(t, s.do(http.MethodDelete, "/v1/admin/delete/"+a.ID, superadminToken, nil), http.StatusOK, nil)
+	expectError(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": a.Tokens.RefreshToken}),
+		http.StatusUnauthorized, "account no longer exists")
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t)
+	u := s.registerUser()
+
+	expect(t, s.do(http.MethodPost, "/v1/auth/logout", u.Tokens.AccessToken, map[string]string{
+		"refresh_token": u.Tokens.RefreshToken,
+	}), http.StatusOK, nil)
+
+	expectError(t, s.do(http.MethodGet, "/v1/me", u.Tokens.AccessToken, nil),
+		http.StatusUnauthorized, "token has been revoked")
+	expectError(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": u.Tokens.RefreshToken}),
+		http.StatusUnauthorized, "refresh token has been revoked")
+
+	other := s.registerUser()
+	expectError(t, s.do(http.MethodPost, "/v1/auth/logout", other.Tokens.AccessToken, map[string]string{
+		"refresh_token": s.registerUser().Tokens.RefreshToken,
+	}), http.StatusBadRequest, "invalid refresh token")
+}
+
+func TestLogoutAll(t *testing.T) {
+	s := newTestServer(t)
+	u := s.registerUser()
+
+	var second models.TokenResponse
+	expect(t, s.do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"user_login":    u.Login,
+		"user_password": u.Password,
+	}), http.StatusOK, &second)
+
+	expect(t, s.do(http.MethodPost, "/v1/auth/logout-all", u.Tokens.AccessToken, nil), http.StatusOK, nil)
+
+	for _, token := range []string{u.Tokens.AccessToken, second.AccessToken} {
+		expectError(t, s.do(http.MethodGet, "/v1/me", token, nil), http.StatusUnauthorized, "token has been revoked")
+	}
+	expectError(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": second.RefreshToken}),
+		http.StatusUnauthorized, "refresh token has been revoked")
+
+	// a login right after the logout-all, likely within the same second
+	var third models.TokenResponse
+	expect(t, s.do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"user_login":    u.Login,
+		"user_password": u.Password,
+	}), http.StatusOK, &third)
+	expect(t, s.do(http.MethodGet, "/v1/me", third.AccessToken, nil), http.StatusOK, nil)
+}
+
+func TestLogoutAllWithinTheSecond(t *testing.T) {
+	s := newTestServer(t)
+	u := s.registerUser()
+
+	// start at a second, so the tokens and the logout-all share it
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	start := time.Now()
+
+	var other models.TokenResponse
+	expect(t, s.do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"user_login":    u.Login,
+		"user_password": u.Password,
+	}), http.StatusOK, &other)
+	legacy := legacyTokens(t, u)
+
+	expect(t, s.do(http.MethodPost, "/v1/auth/logout-all", other.AccessToken, nil), http.StatusOK, nil)
+	if time.Now().Truncate(time.Second) != start.Truncate(time.Second) {
+		t.Skip("the logout-all did not happen in the second of the tokens")
+	}
+
+	for _, token := range []string{u.Tokens.AccessToken, other.AccessToken, legacy.AccessToken} {
+		expectError(t, s.do(http.MethodGet, "/v1/me", token, nil), http.StatusUnauthorized, "token has been revoked")
+	}
+	for _, token := range []string{other.RefreshToken, legacy.RefreshToken} {
+		expectError(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": token}),
+			http.StatusUnauthorized, "refresh token has been revoked")
+	}
+}
+
+// legacyTokens signs a token pair the way the backend services do: HS256 with
+// the shared secret, without the typ and jti claims of the tokens of GenJWT.
+func legacyTokens(t *testing.T, a account) models.TokenResponse {
+	t.Helper()
+
+	sign := func(ttl time.Duration) string {
+		now := time.Now()
+		token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, gojwt.MapClaims{
+			"user_id":   a.ID,
+			"user_role": user,
+			"iss":       "user",
+			"iat":       now.Unix(),
+			"exp":       now.Add(ttl).Unix(),
+		}).SignedString([]byte(testSigningKey))
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		return token
+	}
+	return models.TokenResponse{AccessToken: sign(jwt.AccessTokenTTL), RefreshToken: sign(jwt.RefreshTokenTTL)}
+}
+
+func TestLegacyTokens(t *testing.T) {
+	s := newTestServer(t)
+
+	t.Run("middleware", func(t *testing.T) {
+		u := s.registerUser()
+		tokens := legacyTokens(t, u)
+
+		if me := s.me(tokens.AccessToken); me.UserID != u.ID || me.UserRole != user {
+			t.Fatalf("me = %+v, want %s", me, u.ID)
+		}
+		// told apart by their lifetime
+		expectError(t, s.do(http.MethodGet, "/v1/me", tokens.RefreshToken, nil), http.StatusUnauthorized, "access token expected")
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		u := s.registerUser()
+		tokens := legacyTokens(t, u)
+
+		expectError(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": tokens.AccessToken}),
+			http.StatusUnauthorized, "refresh token expected")
+
+		var fresh models.TokenResponse
+		expect(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": tokens.RefreshToken}),
+			http.StatusOK, &fresh)
+		if me := s.me(fresh.AccessToken); me.UserID != u.ID {
+			t.Fatalf("user = %q, want %q", me.UserID, u.ID)
+		}
+		// revoked by the hash of the token, there is no jti
+		expectError(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": tokens.RefreshToken}),
+			http.StatusUnauthorized, "refresh token has been revoked")
+	})
+
+	t.Run("logout", func(t *testing.T) {
+		u := s.registerUser()
+		tokens := legacyTokens(t, u)
+
+		expect(t, s.do(http.MethodPost, "/v1/auth/logout", tokens.AccessToken, map[string]string{
+			"refresh_token": tokens.RefreshToken,
+		}), http.StatusOK, nil)
+
+		expectError(t, s.do(http.MethodGet, "/v1/me", tokens.AccessToken, nil),
+			http.StatusUnauthorized, "token has been revoked")
+		expectError(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": tokens.RefreshToken}),
+			http.StatusUnauthorized, "refresh token has been revoked")
+		// the session of the login is not the one logged out
+		if me := s.me(u.Tokens.AccessToken); me.UserID != u.ID {
+			t.Fatalf("user = %q, want %q", me.UserID, u.ID)
+		}
+	})
+}
+
+// serviceLogins answers every login with the tokens of a service.
+type serviceLogins struct {
+	user_service.UnimplementedUserServiceServer
+	tokens models.TokenResponse
+}
+
+func (s *serviceLogins) Login(context.Context, *user_service.UserLoginRequest) (*user_service.UserLoginResponse, error) {
+	return &user_service.UserLoginResponse{AccessToken: s.tokens.AccessToken, RefreshToken: s.tokens.RefreshToken}, nil
+}
+
+func TestLoginWithKeySet(t *testing.T) {
+	dir := t.TempDir()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "2024-01.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keySet, err := jwt.LoadKeySet(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwt.SetKeySet(keySet)
+	t.Cleanup(func() { jwt.SetKeySet(nil) })
+
+	// HS256 tokens without kid, as the services sign them
+	service := legacyTokens(t, account{ID: "service-user"})
+	s := newStubServer(t, func(s *grpc.Server) {
+		user_service.RegisterUserServiceServer(s, &serviceLogins{tokens: service})
+	})
+
+	var tokens models.TokenResponse
+	expect(t, s.do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"user_login":    "service@gmail.com",
+		"user_password": "Passw0rd!",
+	}), http.StatusOK, &tokens)
+	if me := s.me(tokens.AccessToken); me.UserID != "service-user" || me.UserRole != user {
+		t.Fatalf("me = %+v, want service-user", me)
+	}
+
+	for _, token := range []string{service.AccessToken, service.RefreshToken} {
+		expectError(t, s.do(http.MethodGet, "/v1/me", token, nil), http.StatusUnauthorized, "token has no key id")
+	}
+	expectError(t, s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": service.RefreshToken}),
+		http.StatusUnauthorized, "token has no key id")
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := newTestServer(t)
+	u := s.registerUser()
+	superToken := s.loginSuperadmin().AccessToken
+
+	login := func(password string) map[string]string {
+		return map[string]string{"user_login": u.Login, "user_password": password}
+	}
+
+	// LoginMaxFailures of testConfig
+	for i := 0; i < 3; i++ {
+		expectError(t, s.do(http.MethodPost, "/v1/user/login", "", login("wrong")),
+			http.StatusUnauthorized, "wrong login or password")
+	}
+
+	var locked models.LockoutError
+	expect(t, s.do(http.MethodPost, "/v1/user/login", "", login(u.Password)), http.StatusLocked, &locked)
+	if locked.RetryAfter <= 0 || locked.UnlockAt.IsZero() {
+		t.Fatalf("lockout = %+v", locked)
+	}
+
+	var entries []struct {
+		Key      string `json:"key"`
+		Failures int    `json:"failures"`
+	}
+	expect(t, s.do(http.MethodGet, "/v1/admin/lockouts", superToken, nil), http.StatusOK, &entries)
+	found := false
+	for _, e := range entries {
+		found = found || (e.Key == "user:"+u.Login && e.Failures == 3)
+	}
+	if !found {
+		t.Fatalf("lockouts = %+v, want user:%s with 3 failures", entries, u.Login)
+	}
+
+	expect(t, s.do(http.MethodDelete, "/v1/admin/lockouts/user:"+u.Login, superToken, nil), http.StatusOK, nil)
+	expectError(t, s.do(http.MethodDelete, "/v1/admin/lockouts/user:"+u.Login, superToken, nil),
+		http.StatusNotFound, "no failed logins for key")
+
+	expect(t, s.do(http.MethodPost, "/v1/user/login", "", login(u.Password)), http.StatusOK, nil)
+}
+
+// slowLogins rejects every login after a delay, so concurrent logins overlap.
+type slowLogins struct {
+	user_service.UnimplementedUserServiceServer
+	calls atomic.Int32
+}
+
+func (s *slowLogins) Login(context.Context, *user_service.UserLoginRequest) (*user_service.UserLoginResponse, error) {
+	s.calls.Add(1)
+	time.Sleep(50 * time.Millisecond)
+	return nil, status.Error(codes.Unauthenticated, "wrong login or password")
+}
+
+func TestLoginLockoutUnderConcurrency(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		inFlight  int
+		wantCalls int32
+	}{
+		// LoginMaxFailures of testConfig, every login reserves a failure before it is made
+		{"failures", 0, 3},
+		{"in flight", 1, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			logins := &slowLogins{}
+			s := newStubServer(t, func(s *grpc.Server) {
+				user_service.RegisterUserServiceServer(s, logins)
+			}, withConfig(func(cfg *config.Config) {
+				cfg.LoginMaxInFlight = tc.inFlight
+			}))
+
+			statuses := make(chan int, 50)
+			var wg sync.WaitGroup
+			for i := 0; i < cap(statuses); i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					statuses <- s.do(http.MethodPost, "/v1/user/login", "", map[string]string{
+						"user_login":    "victim@gmail.com",
+						"user_password": "wrong",
+					}).Code
+				}()
+			}
+			wg.Wait()
+			close(statuses)
+
+			refused := 0
+			for code := range statuses {
+				if code == http.StatusLocked || code == http.StatusTooManyRequests {
+					refused++
+				}
+			}
+			if calls := logins.calls.Load(); calls > tc.wantCalls {
+				t.Fatalf("%d logins reached the service, want at most %d", calls, tc.wantCalls)
+			}
+			if refused < cap(statuses)-int(tc.wantCalls) {
+				t.Fatalf("%d logins refused, want at least %d", refused, cap(statuses)-int(tc.wantCalls))
+			}
+		})
+	}
+}
+
+func TestLoginLockoutByClientAddress(t *testing.T) {
+	// httptest requests come from 192.0.2.1
+	for _, tc := range []struct {
+		name    string
+		proxies []string
+		want    int
+	}{
+		{"forwarded for is ignored", nil, http.StatusLocked},
+		{"forwarded for from a trusted proxy", []string{"192.0.2.0/24"}, http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t, withConfig(func(cfg *config.Config) {
+				cfg.LoginIPMaxFailures = 2
+				cfg.TrustedProxies = tc.proxies
+			}))
+
+			var w *httptest.ResponseRecorder
+			for i := 0; i < 3; i++ {
+				w = s.doWithHeaders(http.MethodPost, "/v1/user/login", "",
+					map[string]string{"X-Forwarded-For": fmt.Sprintf("198.51.100.%d", i)},
+					map[string]string{"user_login": newMail(), "user_password": "wrong"})
+			}
+			if w.Code != tc.want {
+				t.Fatalf("status = %d, want %d, body: %s", w.Code, tc.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s := newTestServer(t, withRateLimits("2/1m", "off"))
+
+	body := map[string]string{"user_login": "nobody@gmail.com", "user_password": "wrong"}
+	for i := 0; i < 2; i++ {
+		w := s.do(http.MethodPost, "/v1/user/login", "", body)
+		if w.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Fatalf("X-RateLimit-Limit = %q, want 2", w.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+
+	w := s.do(http.MethodPost, "/v1/user/login", "", body)
+	expectError(t, w, http.StatusTooManyRequests, "Too Many Requests")
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("no Retry-After header")
+	}
+
+	// the secured group is not limited
+	token := tokenFor(t, user)
+	for i := 0; i < 5; i++ {
+		expect(t, s.do(http.MethodGet, "/v1/me", token, nil), http.StatusOK, nil)
+	}
+}
+
+func TestRateLimitIgnoresForwardedFor(t *testing.T) {
+	s := newTestServer(t, withRateLimits("2/1m", "off"))
+
+	body := map[string]string{"user_login": "nobody@gmail.com", "user_password": "wrong"}
+	for i, want := range []string{"1", "0"} {
+		w := s.doWithHeaders(http.MethodPost, "/v1/user/login", "",
+			map[string]string{"X-Forwarded-For": fmt.Sprintf("198.51.100.%d", i)}, body)
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != want {
+			t.Fatalf("X-RateLimit-Remaining = %q, want %s", got, want)
+		}
+	}
+
+	w := s.doWithHeaders(http.MethodPost, "/v1/user/login", "",
+		map[string]string{"X-Forwarded-For": "198.51.100.99"}, body)
+	expectError(t, w, http.StatusTooManyRequests, "Too Many Requests")
+}
+
+func TestRateLimitByAPIKey(t *testing.T) {
+	const partnerKey = "partner-api-key"
+	s := newTestServer(t, withRateLimits("2/1m", "off"), withConfig(func(cfg *config.Config) {
+		cfg.RateLimitAuthKey = ratelimit.KeyByAPIKey
+		cfg.RateLimitAPIKeyHeader = "X-API-Key"
+		cfg.RateLimitAPIKeys = []string{ratelimit.HashAPIKey(partnerKey)}
+	}))
+
+	body := map[string]string{"user_login": "nobody@gmail.com", "user_password": "wrong"}
+	login := func(apiKey string) *httptest.ResponseRecorder {
+		t.Helper()
+		return s.doWithHeaders(http.MethodPost, "/v1/user/login", "", map[string]string{"X-API-Key": apiKey}, body)
+	}
+
+	// the allow-listed key has a bucket of its own
+	for _, want := range []string{"1", "0"} {
+		if got := login(partnerKey).Header().Get("X-RateLimit-Remaining"); got != want {
+			t.Fatalf("X-RateLimit-Remaining = %q, want %s", got, want)
+		}
+	}
+	expectError(t, login(partnerKey), http.StatusTooManyRequests, "Too Many Requests")
+
+	// unknown keys share the bucket of the address, rotating them gives no fresh one
+	for i, want := range []string{"1", "0"} {
+		if got := login(fmt.Sprintf("rotated-key-%d", i)).Header().Get("X-RateLimit-Remaining"); got != want {
+			t.Fatalf("X-RateLimit-Remaining = %q, want %s", got, want)
+		}
+	}
+	expectError(t, login("rotated-key-99"), http.StatusTooManyRequests, "Too Many Requests")
+	expectError(t, login(""), http.StatusTooManyRequests, "Too Many Requests")
+}
+
+func TestJWKS(t *testing.T) {
+	s := newTestServer(t)
+
+	var keys struct {
+		Keys []interface{} `json:"keys"`
+	}
+	w := s.do(http.MethodGet, "/.well-known/jwks.json", "", nil)
+	expect(t, w, http.StatusOK, &keys)
+	if keys.Keys == nil {
+		t.Fatalf("body = %s, want a key list", w.Body.String())
+	}
+}
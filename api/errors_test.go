@@ -0,0 +1,153 @@
+package api_test
+
+import (
+	"api_gateway/api/models"
+	"api_gateway/config"
+	"api_gateway/pkg/grpc_client"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestGrpcErrorMapping(t *testing.T) {
+	for _, tc := range []struct {
+		code   codes.Code
+		status int
+		detail string
+	}{
+		{codes.Canceled, 499, "boom"},
+		{codes.Unknown, http.StatusInternalServerError, ""},
+		{codes.InvalidArgument, http.StatusBadRequest, "boom"},
+		{codes.NotFound, http.StatusNotFound, "boom"},
+		{codes.AlreadyExists, http.StatusConflict, "boom"},
+		{codes.PermissionDenied, http.StatusForbidden, "boom"},
+		{codes.ResourceExhausted, http.StatusTooManyRequests, "boom"},
+		{codes.FailedPrecondition, http.StatusBadRequest, "boom"},
+		{codes.Aborted, http.StatusConflict, "boom"},
+		{codes.OutOfRange, http.StatusBadRequest, "boom"},
+		{codes.Unimplemented, http.StatusNotImplemented, ""},
+		{codes.Internal, http.StatusInternalServerError, ""},
+		{codes.Unavailable, http.StatusServiceUnavailable, ""},
+		{codes.DataLoss, http.StatusInternalServerError, ""},
+		{codes.Unauthenticated, http.StatusUnauthorized, "boom"},
+	} {
+		t.Run(tc.code.String(), func(t *testing.T) {
+			s := newTaskStubServer(t, &stubTasks{err: status.Error(tc.code, "boom")})
+
+			p := expectError(t, s.do(http.MethodGet, "/v1/me/tasks", tokenFor(t, user), nil), tc.status, tc.detail)
+			if p.Instance != "/v1/me/tasks" {
+				t.Fatalf("instance = %q, want the request path", p.Instance)
+			}
+		})
+	}
+}
+
+func TestGrpcFieldViolations(t *testing.T) {
+	st, err := status.New(codes.InvalidArgument, "invalid task").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "title", Description: "must not be empty"},
+			{Field: "deadline", Description: "must be in the future"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTaskStubServer(t, &stubTasks{err: st.Err()})
+
+	p := expectError(t, s.do(http.MethodGet, "/v1/me/tasks", tokenFor(t, user), nil), http.StatusBadRequest, "invalid task")
+	want := []models.Violation{
+		{Field: "title", Description: "must not be empty"},
+		{Field: "deadline", Description: "must be in the future"},
+	}
+	if !reflect.DeepEqual(p.Violations, want) {
+		t.Fatalf("violations = %+v, want %+v", p.Violations, want)
+	}
+}
+
+func TestGrpcUnimplementedService(t *testing.T) {
+	s := newStubServer(t, func(*grpc.Server) {})
+
+	p := expectError(t, s.do(http.MethodGet, "/v1/me/tasks", tokenFor(t, user), nil), http.StatusNotImplemented, "")
+	if p.Code != "NOT_IMPLEMENTED" {
+		t.Fatalf("code = %q, want NOT_IMPLEMENTED", p.Code)
+	}
+}
+
+func TestGrpcTimeout(t *testing.T) {
+	tasks := &stubTasks{delay: time.Second}
+	s := newTaskStubServer(t, tasks, withConfig(func(cfg *config.Config) {
+		cfg.GrpcTimeout = 20 * time.Millisecond
+	}))
+
+	start := time.Now()
+	expectError(t, s.do(http.MethodGet, "/v1/me/tasks", tokenFor(t, user), nil),
+		http.StatusGatewayTimeout, "")
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("request took %v, want it cut at the deadline", elapsed)
+	}
+}
+
+func TestGrpcRetries(t *testing.T) {
+	tasks := &stubTasks{err: status.Error(codes.Unavailable, "down")}
+	s := newTaskStubServer(t, tasks)
+
+	expectError(t, s.do(http.MethodGet, "/v1/me/tasks", tokenFor(t, user), nil),
+		http.StatusServiceUnavailable, "")
+	// GrpcRetryMaxAttempts of testConfig
+	if calls := tasks.calls.Load(); calls != 2 {
+		t.Fatalf("calls = %d, want 2 attempts", calls)
+	}
+}
+
+func TestGrpcCircuitBreaker(t *testing.T) {
+	tasks := &stubTasks{err: status.Error(codes.Unavailable, "down")}
+	s := newTaskStubServer(t, tasks, withConfig(func(cfg *config.Config) {
+		cfg.GrpcRetryMaxAttempts = 1
+		cfg.BreakerFailureThreshold = 2
+	}))
+	token := tokenFor(t, user)
+
+	for i := 0; i < 2; i++ {
+		expectError(t, s.do(http.MethodGet, "/v1/me/tasks", token, nil),
+			http.StatusServiceUnavailable, "")
+	}
+
+	w := s.do(http.MethodGet, "/v1/me/tasks", token, nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retryAfter <= 0 {
+		t.Fatalf("Retry-After = %q, want seconds until the breaker half-opens", w.Header().Get("Retry-After"))
+	}
+	if calls := tasks.calls.Load(); calls != 2 {
+		t.Fatalf("calls = %d, want none while the breaker is open", calls)
+	}
+}
+
+func TestReadyzReportsDownServices(t *testing.T) {
+	s := newStubServer(t, func(s *grpc.Server) {
+		hs := health.NewServer()
+		hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+		healthpb.RegisterHealthServer(s, hs)
+	})
+
+	var ready models.ReadinessResponse
+	expect(t, s.do(http.MethodGet, "/readyz", "", nil), http.StatusServiceUnavailable, &ready)
+	if ready.Status != "not_ready" {
+		t.Fatalf("status = %q, want not_ready", ready.Status)
+	}
+	for _, service := range []string{grpc_client.UserServiceName, grpc_client.TaskServiceName} {
+		if dep := ready.Dependencies[service]; dep.Status != "down" || dep.Error == "" {
+			t.Fatalf("%s = %+v, want down", service, dep)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"api_gateway/api/models"
+	"api_gateway/config"
+	"api_gateway/genproto/admin_service"
+	"api_gateway/genproto/user_service"
+	"api_gateway/pkg/jwt"
+	"api_gateway/pkg/logger"
+	"api_gateway/pkg/metrics"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RefreshToken godoc
+// @Router       /v1/auth/refresh [POST]
+// @Summary      Refresh tokens
+// @Description  Exchanges a refresh token for a new access/refresh token pair. The old refresh token stops working, tokens of deleted accounts are refused.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        refresh body models.RefreshTokenRequest true "refresh token"
+// @Success		 200  {object}  models.TokenResponse
+// @Failure		 400  {object}  models.Problem
+// @Failure		 401  {object}  models.Problem
+// @Failure		 500  {object}  models.Problem
+func (h *handler) RefreshToken(c *gin.Context) {
+	req := &models.RefreshTokenRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		abortWithInvalidJSON(c, err)
+		return
+	}
+
+	refreshToken := strings.TrimSpace(strings.TrimPrefix(req.RefreshToken, "Bearer "))
+	claims, err := jwt.ExtractClaims(refreshToken)
+	if err != nil {
+		metrics.AuthFailure(metrics.AuthFailureInvalidToken)
+		abortWithStatus(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if jwt.TokenType(claims) != jwt.RefreshTokenType {
+		metrics.AuthFailure(metrics.AuthFailureInvalidToken)
+		abortWithStatus(c, http.StatusUnauthorized, "refresh token expected")
+		return
+	}
+
+	userID, _ := claims["user_id"].(string)
+	revoked, err := h.isRevoked(c.Request.Context(), jwt.TokenID(claims, refreshToken), userID, jwt.IssuedAt(claims))
+	if err != nil {
+		h.logFor(c).Error("error while checking token revocation", logger.Error(err))
+		abortWithStatus(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if revoked {
+		metrics.AuthFailure(metrics.AuthFailureRevokedToken)
+		abortWithStatus(c, http.StatusUnauthorized, "refresh token has been revoked")
+		return
+	}
+
+	role, ok := h.accountRole(c, userID, claims["user_role"])
+	if !ok {
+		return
+	}
+
+	fresh, err := h.tokenStore.Revoke(c.Request.Context(), jwt.TokenID(claims, refreshToken), jwt.ExpiresAt(claims))
+	if err != nil {
+		h.logFor(c).Error("error while revoking refresh token", logger.Error(err))
+		abortWithStatus(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if !fresh {
+		metrics.AuthFailure(metrics.AuthFailureRevokedToken)
+		abortWithStatus(c, http.StatusUnauthorized, "refresh token has already been used")
+		return
+	}
+
+	m := jwt.CustomClaims(claims)
+	m["user_role"] = role
+	accessToken, newRefreshToken, err := jwt.GenJWT(m)
+	if err != nil {
+		h.logFor(c).Error("error while generating tokens", logger.Error(err))
+		abortWithStatus(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+	})
+}
+
+// accountRole looks the subject of a refresh token up in the service of its
+// role and returns the role to issue, so deleted accounts get no new tokens.
+// The service it is found in decides between user and admin, GetAdmin carries
+// no role so an admin keeps the superadmin role of its claims.
+// It aborts the request and returns false when the account is gone.
+func (h *handler) accountRole(c *gin.Context, userID string, claimed interface{}) (string, bool) {
+	var (
+		role = config.USER_ROLE
+		err  error
+	)
+	switch claimed {
+	case config.ADMIN_ROLE, config.SUPERADMIN_ROLE:
+		role = claimed.(string)
+		_, err = h.grpcClient.AdminService().GetByID(c.Request.Context(), &admin_service.AdminPrimaryKey{Id: userID})
+	case config.USER_ROLE:
+		_, err = h.grpcClient.UserService().GetByID(c.Request.Context(), &user_service.UserPrimaryKey{Id: userID})
+	default:
+		metrics.AuthFailure(metrics.AuthFailureInvalidToken)
+		abortWithStatus(c, http.StatusUnauthorized, "token has unknown user_role claim")
+		return "", false
+	}
+
+	if status.Code(err) == codes.NotFound {
+		metrics.AuthFailure(metrics.AuthFailureInvalidToken)
+		abortWithStatus(c, http.StatusUnauthorized, "account no longer exists")
+		return "", false
+	}
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while getting account")
+		return "", false
+	}
+	return role, true
+}
+
+// reissueTokens exchanges the tokens a service answered a login with for
+// tokens of the key set, see jwt.ReissueSharedSecretTokens. It aborts the
+// request and returns false when they cannot be verified.
+func (h *handler) reissueTokens(c *gin.Context, accessToken, refreshToken *string) bool {
+	access, refresh, err := jwt.ReissueSharedSecretTokens(*accessToken, *refreshToken)
+	if err != nil {
+		h.logFor(c).Error("error while reissuing service tokens", logger.Error(err))
+		abortWithStatus(c, http.StatusInternalServerError, "Internal Server Error")
+		return false
+	}
+	*accessToken, *refreshToken = access, refresh
+	return true
+}
+
+// @Security ApiKeyAuth
+// @Param   Authorization  header  string  true  "Authorization token"
+// @Router /v1/auth/logout [POST]
+// @Summary Logout
+// @Description Revokes the access token of the request and, when given, the refresh token of the same session
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param		logout body  models.LogoutRequest false "refresh token"
+// @Success		200  {object}  models.ResponseOK
+// @Failure		400  {object}  models.Problem
+// @Failure		401  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
+func (h *handler) Logout(c *gin.Context) {
+	authInfo := getAuthInfo(c)
+
+	req := &models.LogoutRequest{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(req); err != nil {
+			abortWithInvalidJSON(c, err)
+			return
+		}
+	}
+
+	if _, err := h.tokenStore.Revoke(c.Request.Context(), authInfo.TokenID, authInfo.ExpiresAt); err != nil {
+		h.logFor(c).Error("error while revoking access token", logger.Error(err))
+		abortWithStatus(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	if req.RefreshToken != "" {
+		refreshToken := strings.TrimSpace(strings.TrimPrefix(req.RefreshToken, "Bearer "))
+		claims, err := jwt.ExtractClaims(refreshToken)
+		if err != nil || jwt.TokenType(claims) != jwt.RefreshTokenType || claims["user_id"] != authInfo.UserID {
+			abortWithStatus(c, http.StatusBadRequest, "invalid refresh token")
+			return
+		}
+		if _, err := h.tokenStore.Revoke(c.Request.Context(), jwt.TokenID(claims, refreshToken), jwt.ExpiresAt(claims)); err != nil {
+			h.logFor(c).Error("error while revoking refresh token", logger.Error(err))
+			abortWithStatus(c, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.ResponseOK{Message: "logged out"})
+}
+
+// @Security ApiKeyAuth
+// @Param   Authorization  header  string  true  "Authorization token"
+// @Router /v1/auth/logout-all [POST]
+// @Summary Logout from all sessions
+// @Description Revokes the access token of the request and every access and refresh token of the caller issued before the call
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Success		200  {object}  models.ResponseOK
+// @Failure		401  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
+func (h *handler) LogoutAll(c *gin.Context) {
+	authInfo := getAuthInfo(c)
+
+	// see isRevoked for tokens with a whole-second iat
+	err := h.tokenStore.RevokeIssuedBefore(c.Request.Context(), authInfo.UserID, time.Now(), jwt.RefreshTokenTTL)
+	if err != nil {
+		h.logFor(c).Error("error while revoking user tokens", logger.Error(err))
+		abortWithStatus(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if _, err := h.tokenStore.Revoke(c.Request.Context(), authInfo.TokenID, authInfo.ExpiresAt); err != nil {
+		h.logFor(c).Error("error while revoking access token", logger.Error(err))
+		abortWithStatus(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ResponseOK{Message: "logged out from all sessions"})
+}
+
+// JWKS godoc
+// @Router       /.well-known/jwks.json [GET]
+// @Summary      JSON Web Key Set
+// @Description  Public keys for verifying tokens issued by the gateway
+// @Tags         auth
+// @Produce      json
+// @Success		 200  {object}  jwt.JWKS
+func (h *handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwt.PublicKeys())
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Security ApiKeyAuth
+// @Param   Authorization  header  string  true  "Authorization token"
+// @Router /v1/debug/breakers [GET]
+// @Summary Get circuit breakers
+// @Description API for inspecting the circuit breaker state of every downstream service
+// @Tags debug
+// @Accept  json
+// @Produce  json
+// @Success		200  {array}   grpc_client.BreakerStatus
+// @Failure		401  {object}  models.Problem
+// @Failure		403  {object}  models.Problem
+func (h *handler) GetBreakers(c *gin.Context) {
+	c.JSON(http.StatusOK, h.grpcClient.Breakers())
+}
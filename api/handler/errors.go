@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"api_gateway/api/models"
+	"api_gateway/pkg/grpc_client"
+	"api_gateway/pkg/logger"
+	"api_gateway/pkg/requestid"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// problemContentType is the media type of error responses (RFC 7807).
+	problemContentType = "application/problem+json"
+	// problemType is the type of every Problem, the code tells them apart.
+	problemType = "about:blank"
+
+	// statusClientClosedRequest answers calls canceled by the client.
+	statusClientClosedRequest = 499
+)
+
+// grpcError is the answer of the gateway to a gRPC status code.
+type grpcError struct {
+	status int
+	code   string
+}
+
+// grpcErrors maps every gRPC status code to an HTTP status and an ErrorCode*.
+// Codes missing here are answered as internal errors.
+var grpcErrors = map[codes.Code]grpcError{
+	codes.Canceled:           {statusClientClosedRequest, ErrorCodeCanceled},
+	codes.Unknown:            {http.StatusInternalServerError, ErrorCodeInternal},
+	codes.InvalidArgument:    {http.StatusBadRequest, ErrorBadRequest},
+	codes.DeadlineExceeded:   {http.StatusGatewayTimeout, ErrorCodeTimeout},
+	codes.NotFound:           {http.StatusNotFound, ErrorCodeNotFound},
+	codes.AlreadyExists:      {http.StatusConflict, ErrorCodeAlreadyExists},
+	codes.PermissionDenied:   {http.StatusForbidden, ErrorCodeForbidden},
+	codes.ResourceExhausted:  {http.StatusTooManyRequests, ErrorCodeTooManyRequests},
+	codes.FailedPrecondition: {http.StatusBadRequest, ErrorCodeFailedPrecondition},
+	codes.Aborted:            {http.StatusConflict, ErrorCodeConflict},
+	codes.OutOfRange:         {http.StatusBadRequest, ErrorCodeOutOfRange},
+	codes.Unimplemented:      {http.StatusNotImplemented, ErrorCodeNotImplemented},
+	codes.Internal:           {http.StatusInternalServerError, ErrorCodeInternal},
+	codes.Unavailable:        {http.StatusServiceUnavailable, ErrorCodeUnavailable},
+	codes.DataLoss:           {http.StatusInternalServerError, ErrorCodeInternal},
+	codes.Unauthenticated:    {http.StatusUnauthorized, ErrorCodeUnauthorized},
+
+	// the backend services answer invalid fields with the non-standard code 20
+	codes.Code(20): {http.StatusBadRequest, ErrorBadRequest},
+}
+
+// statusErrorCodes are the ErrorCode* of the errors the gateway answers on its own.
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:          ErrorBadRequest,
+	http.StatusUnauthorized:        ErrorCodeUnauthorized,
+	http.StatusForbidden:           ErrorCodeForbidden,
+	http.StatusNotFound:            ErrorCodeNotFound,
+	http.StatusConflict:            ErrorCodeConflict,
+	http.StatusLocked:              ErrorCodeLocked,
+	http.StatusTooManyRequests:     ErrorCodeTooManyRequests,
+	http.StatusInternalServerError: ErrorCodeInternal,
+	http.StatusNotImplemented:      ErrorCodeNotImplemented,
+	http.StatusServiceUnavailable:  ErrorCodeUnavailable,
+	http.StatusGatewayTimeout:      ErrorCodeTimeout,
+}
+
+// handleGrpcErrWithDescription answers a failed gRPC call with the Problem of
+// its status code and logs it with message. Field violations sent by the
+// service as errdetails.BadRequest are passed on, messages of 5xx answers are
+// not, they may leak internals of the service. It reports whether err is set.
+func handleGrpcErrWithDescription(c *gin.Context, l logger.Logger, err error, message string) bool {
+	if err == nil {
+		return false
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		l.Error(message+", not a grpc error", logger.Error(err))
+		abortWithProblem(c, newProblem(c, http.StatusInternalServerError, ErrorCodeInternal, ""))
+		return true
+	}
+
+	mapped, ok := grpcErrors[st.Code()]
+	if !ok {
+		mapped = grpcError{http.StatusInternalServerError, ErrorCodeInternal}
+	}
+
+	problem := newProblem(c, mapped.status, mapped.code, st.Message())
+	if mapped.status >= http.StatusInternalServerError {
+		problem.Detail = ""
+	}
+	problem.Violations = fieldViolations(st)
+
+	var open *grpc_client.CircuitOpenError
+	if errors.As(err, &open) {
+		problem.Detail = open.Error()
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(time.Until(open.RetryAt))))
+	}
+
+	fields := []logger.Field{logger.Error(err), logger.String("grpc_code", st.Code().String())}
+	if mapped.status >= http.StatusInternalServerError {
+		l.Error(message, fields...)
+	} else {
+		l.Warn(message, fields...)
+	}
+
+	abortWithProblem(c, problem)
+	return true
+}
+
+// fieldViolations decodes the errdetails.BadRequest details of a status.
+func fieldViolations(st *status.Status) []models.Violation {
+	var violations []models.Violation
+	for _, detail := range st.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		if !ok {
+			continue
+		}
+		for _, v := range badRequest.GetFieldViolations() {
+			violations = append(violations, models.Violation{
+				Field:       v.GetField(),
+				Description: v.GetDescription(),
+			})
+		}
+	}
+	return violations
+}
+
+// abortWithStatus answers with the Problem of status and stops the handler chain.
+func abortWithStatus(c *gin.Context, status int, detail string) {
+	code, ok := statusErrorCodes[status]
+	if !ok {
+		code = ErrorCodeInternal
+	}
+	abortWithProblem(c, newProblem(c, status, code, detail))
+}
+
+// abortWithInvalidJSON answers a request body that does not bind.
+func abortWithInvalidJSON(c *gin.Context, err error) {
+	problem := newProblem(c, http.StatusBadRequest, ErrorCodeInvalidJSON, err.Error())
+
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		problem.Detail = "invalid request body"
+		for _, fe := range invalid {
+			problem.Violations = append(problem.Violations, models.Violation{
+				Field:       fe.Field(),
+				Description: fmt.Sprintf("failed on the %q rule", fe.Tag()),
+			})
+		}
+	}
+	abortWithProblem(c, problem)
+}
+
+// abortWithViolation answers a request with a field that failed validation.
+func abortWithViolation(c *gin.Context, detail, field, description string) {
+	problem := newProblem(c, http.StatusBadRequest, ErrorBadRequest, detail)
+	problem.Violations = []models.Violation{{Field: field, Description: description}}
+	abortWithProblem(c, problem)
+}
+
+func abortWithProblem(c *gin.Context, problem models.Problem) {
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
+
+func newProblem(c *gin.Context, status int, code, detail string) models.Problem {
+	title := http.StatusText(status)
+	if status == statusClientClosedRequest {
+		title = "Client Closed Request"
+	}
+
+	return models.Problem{
+		Type:      problemType,
+		Title:     title,
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		Code:      code,
+		RequestID: requestID(c),
+	}
+}
+
+// requestID returns the ID set by the RequestID middleware.
+func requestID(c *gin.Context) string {
+	return requestid.FromContext(c.Request.Context())
+}
+
+// NoRoute answers requests for unknown routes.
+func (h *handler) NoRoute(c *gin.Context) {
+	abortWithStatus(c, http.StatusNotFound, fmt.Sprintf("no route for %s %s", c.Request.Method, c.Request.URL.Path))
+}
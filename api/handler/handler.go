@@ -5,27 +5,41 @@ import (
 	"api_gateway/config"
 	"api_gateway/pkg/grpc_client"
 	"api_gateway/pkg/jwt"
+	"api_gateway/pkg/lockout"
 	"api_gateway/pkg/logger"
+	"api_gateway/pkg/ratelimit"
+	"api_gateway/pkg/rbac"
+	"api_gateway/pkg/revocation"
 	"errors"
-	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"github.com/redis/go-redis/v9"
 )
 
 type handler struct {
 	log        logger.Logger
-	grpcClient *grpc_client.GrpcClient
+	grpcClient grpc_client.GrpcClientI
 	cfg        config.Config
+	tokenStore revocation.Store
+	policy     *rbac.Policy
+	limiter    ratelimit.Limiter
+	lockouts   lockout.Store
+	redis      *redis.Client
+	apiKeys    map[string]bool // digests of the API keys rate limited on their own
 }
 
 // HandlerV1Config ...
 type HandlerConfig struct {
 	Logger     logger.Logger
-	GrpcClient *grpc_client.GrpcClient
+	GrpcClient grpc_client.GrpcClientI
 	Cfg        config.Config
+	TokenStore revocation.Store
+	Policy     *rbac.Policy
+	Limiter    ratelimit.Limiter
+	Lockouts   lockout.Store
+	Redis      *redis.Client
 }
 
 const (
@@ -53,73 +67,46 @@ const (
 	ErrorCodeWrongClub = "WRONG_CLUB"
 	// ErrorCodePasswordsNotEqual ...
 	ErrorCodePasswordsNotEqual = "PASSWORDS_NOT_EQUAL"
+	// ErrorCodeCanceled ...
+	ErrorCodeCanceled = "CANCELED"
+	// ErrorCodeConflict ...
+	ErrorCodeConflict = "CONFLICT"
+	// ErrorCodeFailedPrecondition ...
+	ErrorCodeFailedPrecondition = "FAILED_PRECONDITION"
+	// ErrorCodeOutOfRange ...
+	ErrorCodeOutOfRange = "OUT_OF_RANGE"
+	// ErrorCodeTooManyRequests ...
+	ErrorCodeTooManyRequests = "TOO_MANY_REQUESTS"
+	// ErrorCodeLocked ...
+	ErrorCodeLocked = "LOCKED"
+	// ErrorCodeNotImplemented ...
+	ErrorCodeNotImplemented = "NOT_IMPLEMENTED"
+	// ErrorCodeUnavailable ...
+	ErrorCodeUnavailable = "SERVICE_UNAVAILABLE"
+	// ErrorCodeTimeout ...
+	ErrorCodeTimeout = "TIMEOUT"
 )
 
 // New ...
 func New(c *HandlerConfig) *handler {
+	apiKeys := make(map[string]bool, len(c.Cfg.RateLimitAPIKeys))
+	for _, hash := range c.Cfg.RateLimitAPIKeys {
+		apiKeys[hash] = true
+	}
+
 	return &handler{
 		log:        c.Logger,
 		grpcClient: c.GrpcClient,
 		cfg:        c.Cfg,
+		tokenStore: c.TokenStore,
+		policy:     c.Policy,
+		limiter:    c.Limiter,
+		lockouts:   c.Lockouts,
+		redis:      c.Redis,
+		apiKeys:    apiKeys,
 	}
 }
 
-func handleGrpcErrWithDescription(c *gin.Context, l logger.Logger, err error, message string) bool {
-	st, ok := status.FromError(err)
-	if !ok || st.Code() == codes.Internal {
-		c.JSON(http.StatusInternalServerError, models.ErrorWithDescription{
-			Code:        http.StatusBadRequest,
-			Description: st.Message(),
-		})
-		l.Error(message, logger.Error(err))
-		return true
-	}
-	if st.Code() == codes.NotFound {
-		c.JSON(http.StatusNotFound, models.ErrorWithDescription{
-			Code:        http.StatusNotFound,
-			Description: st.Message(),
-		})
-		l.Error(message+", not found", logger.Error(err))
-		return true
-	} else if st.Code() == codes.Unavailable {
-		c.JSON(http.StatusInternalServerError, models.ErrorWithDescription{
-			Code:        http.StatusInternalServerError,
-			Description: "Internal Server Error",
-		})
-		l.Error(message+", service unavailable", logger.Error(err))
-		return true
-	} else if st.Code() == codes.AlreadyExists {
-		c.JSON(http.StatusInternalServerError, models.ErrorWithDescription{
-			Code:        http.StatusInternalServerError,
-			Description: st.Message(),
-		})
-		l.Error(message+", already exists", logger.Error(err))
-		return true
-	} else if st.Code() == codes.InvalidArgument {
-		c.JSON(http.StatusBadRequest, models.ErrorWithDescription{
-			Code:        http.StatusBadRequest,
-			Description: st.Message(),
-		})
-		l.Error(message+", invalid field", logger.Error(err))
-		return true
-	} else if st.Code() == codes.Code(20) {
-		c.JSON(http.StatusBadRequest, models.ErrorWithDescription{
-			Code:        http.StatusBadRequest,
-			Description: st.Message(),
-		})
-		l.Error(message+", invalid field", logger.Error(err))
-		return true
-	} else if st.Err() != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorWithDescription{
-			Code:        http.StatusBadRequest,
-			Description: st.Message(),
-		})
-		l.Error(message+", invalid field", logger.Error(err))
-		return true
-	}
-	return false
-}
-
 func ParsePageQueryParam(c *gin.Context) (uint64, error) {
 	pageStr := c.Query("page")
 	if pageStr == "" {
@@ -151,24 +138,49 @@ func ParseLimitQueryParam(c *gin.Context) (uint64, error) {
 	return limit, nil
 }
 
-func getAuthInfo(c *gin.Context) (models.AuthInfo, error) {
-	accessToken := c.GetHeader("Authorization")
+// logFor returns the logger of a request, its lines carry the request ID.
+func (h *handler) logFor(c *gin.Context) logger.Logger {
+	return logger.WithContext(c.Request.Context(), h.log)
+}
+
+// getAuthInfo returns the caller identity stored by AuthMiddleware.
+// Handlers mounted outside the secured group get an empty AuthInfo.
+func getAuthInfo(c *gin.Context) models.AuthInfo {
+	authInfo, _ := c.Get(authInfoKey)
+	info, _ := authInfo.(models.AuthInfo)
+	return info
+}
+
+// parseAuthInfo validates an Authorization header value and extracts the caller identity.
+// Both "Bearer <token>" and a bare token are accepted.
+func parseAuthInfo(header string) (models.AuthInfo, error) {
+	accessToken := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
 	if accessToken == "" {
-		return models.AuthInfo{}, errors.New("unauthorized")
+		return models.AuthInfo{}, errors.New("authorization header is missing")
 	}
 
 	m, err := jwt.ExtractClaims(accessToken)
 	if err != nil {
 		return models.AuthInfo{}, err
 	}
+	if jwt.TokenType(m) != jwt.AccessTokenType {
+		return models.AuthInfo{}, errors.New("access token expected")
+	}
 
-	role := m["user_role"].(string)
-	if !(role == config.USER_ROLE || role == config.ADMIN_ROLE) {
-		return models.AuthInfo{}, errors.New("unauthorized")
+	userID, _ := m["user_id"].(string)
+	role, _ := m["user_role"].(string)
+	if userID == "" {
+		return models.AuthInfo{}, errors.New("token has no user_id claim")
+	}
+	if !(role == config.USER_ROLE || role == config.ADMIN_ROLE || role == config.SUPERADMIN_ROLE) {
+		return models.AuthInfo{}, errors.New("token has unknown user_role claim")
 	}
 
 	return models.AuthInfo{
-		UserID:   m["user_id"].(string),
-		UserRole: role,
+		UserID:    userID,
+		UserRole:  role,
+		TokenID:   jwt.TokenID(m, accessToken),
+		IssuedAt:  jwt.IssuedAt(m),
+		ExpiresAt: jwt.ExpiresAt(m),
 	}, nil
 }
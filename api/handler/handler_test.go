@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"api_gateway/api/models"
+	"api_gateway/pkg/grpc_client"
+	"api_gateway/pkg/logger"
+	"api_gateway/pkg/requestid"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testLog = logger.New(logger.LevelError, "handler_test")
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestHandleGrpcErrWithDescription(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"not a grpc error", errors.New("boom"), http.StatusInternalServerError, ErrorCodeInternal, ""},
+		{"canceled", status.Error(codes.Canceled, "boom"), 499, ErrorCodeCanceled, "boom"},
+		{"unknown", status.Error(codes.Unknown, "boom"), http.StatusInternalServerError, ErrorCodeInternal, ""},
+		{"invalid argument", status.Error(codes.InvalidArgument, "boom"), http.StatusBadRequest, ErrorBadRequest, "boom"},
+		{"deadline exceeded", status.Error(codes.DeadlineExceeded, "boom"), http.StatusGatewayTimeout, ErrorCodeTimeout, ""},
+		{"not found", status.Error(codes.NotFound, "boom"), http.StatusNotFound, ErrorCodeNotFound, "boom"},
+		{"already exists", status.Error(codes.AlreadyExists, "boom"), http.StatusConflict, ErrorCodeAlreadyExists, "boom"},
+		{"permission denied", status.Error(codes.PermissionDenied, "boom"), http.StatusForbidden, ErrorCodeForbidden, "boom"},
+		{"resource exhausted", status.Error(codes.ResourceExhausted, "boom"), http.StatusTooManyRequests, ErrorCodeTooManyRequests, "boom"},
+		{"failed precondition", status.Error(codes.FailedPrecondition, "boom"), http.StatusBadRequest, ErrorCodeFailedPrecondition, "boom"},
+		{"aborted", status.Error(codes.Aborted, "boom"), http.StatusConflict, ErrorCodeConflict, "boom"},
+		{"out of range", status.Error(codes.OutOfRange, "boom"), http.StatusBadRequest, ErrorCodeOutOfRange, "boom"},
+		{"unimplemented", status.Error(codes.Unimplemented, "boom"), http.StatusNotImplemented, ErrorCodeNotImplemented, ""},
+		{"internal", status.Error(codes.Internal, "boom"), http.StatusInternalServerError, ErrorCodeInternal, ""},
+		{"unavailable", status.Error(codes.Unavailable, "boom"), http.StatusServiceUnavailable, ErrorCodeUnavailable, ""},
+		{"data loss", status.Error(codes.DataLoss, "boom"), http.StatusInternalServerError, ErrorCodeInternal, ""},
+		{"unauthenticated", status.Error(codes.Unauthenticated, "boom"), http.StatusUnauthorized, ErrorCodeUnauthorized, "boom"},
+		{"code 20", status.Error(codes.Code(20), "boom"), http.StatusBadRequest, ErrorBadRequest, "boom"},
+		{"unassigned code", status.Error(codes.Code(99), "boom"), http.StatusInternalServerError, ErrorCodeInternal, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/v1/test", nil)
+			c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), "req-1"))
+
+			if !handleGrpcErrWithDescription(c, testLog, tc.err, "test") {
+				t.Fatal("error not handled")
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+				t.Fatalf("Content-Type = %q, want %q", ct, problemContentType)
+			}
+			var body models.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			want := models.Problem{
+				Type:      problemType,
+				Title:     body.Title,
+				Status:    tc.status,
+				Detail:    tc.detail,
+				Instance:  "/v1/test",
+				Code:      tc.code,
+				RequestID: "req-1",
+			}
+			if w.Code != tc.status || body.Title == "" || !reflect.DeepEqual(body, want) {
+				t.Fatalf("got %d %+v, want %+v", w.Code, body, want)
+			}
+		})
+	}
+}
+
+func TestHandleGrpcErrWithDescriptionNil(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	if handleGrpcErrWithDescription(c, testLog, nil, "test") {
+		t.Fatal("nil error handled")
+	}
+	if w.Body.Len() != 0 {
+		t.Fatalf("body = %s, want none", w.Body.String())
+	}
+}
+
+func TestHandleGrpcErrWithDescriptionCircuitOpen(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	err := &grpc_client.CircuitOpenError{Service: grpc_client.TaskServiceName, RetryAt: time.Now().Add(10 * time.Second)}
+	handleGrpcErrWithDescription(c, testLog, err, "test")
+
+	var body models.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusServiceUnavailable || body.Code != ErrorCodeUnavailable || body.Detail != err.Error() {
+		t.Fatalf("got %d %+v, want 503 %q", w.Code, body, err.Error())
+	}
+	if retryAfter, _ := strconv.Atoi(w.Header().Get("Retry-After")); retryAfter < 9 || retryAfter > 10 {
+		t.Fatalf("Retry-After = %q, want 10", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestHandleGrpcErrWithDescriptionViolations(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	st, err := status.New(codes.InvalidArgument, "invalid user").WithDetails(
+		&errdetails.ErrorInfo{Reason: "ignored"},
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "email", Description: "is taken"},
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handleGrpcErrWithDescription(c, testLog, st.Err(), "test")
+
+	var body models.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := []models.Violation{{Field: "email", Description: "is taken"}}
+	if w.Code != http.StatusBadRequest || !reflect.DeepEqual(body.Violations, want) {
+		t.Fatalf("got %d %+v, want violations %+v", w.Code, body, want)
+	}
+}
+
+func TestAbortWithInvalidJSON(t *testing.T) {
+	type request struct {
+		Title string `json:"title" binding:"required"`
+	}
+
+	for _, tc := range []struct {
+		body       string
+		detail     string
+		violations []models.Violation
+	}{
+		{"{", "unexpected EOF", nil},
+		{"{}", "invalid request body", []models.Violation{{Field: "Title", Description: `failed on the "required" rule`}}},
+	} {
+		t.Run(tc.body, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+
+			var req request
+			err := c.ShouldBindJSON(&req)
+			if err == nil {
+				t.Fatal("body bound")
+			}
+			abortWithInvalidJSON(c, err)
+
+			var body models.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusBadRequest || body.Code != ErrorCodeInvalidJSON || body.Detail != tc.detail ||
+				!reflect.DeepEqual(body.Violations, tc.violations) {
+				t.Fatalf("got %d %+v", w.Code, body)
+			}
+		})
+	}
+}
+
+func queryContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	return c
+}
+
+func TestParsePageQueryParam(t *testing.T) {
+	for _, tc := range []struct {
+		query   string
+		page    uint64
+		wantErr bool
+	}{
+		{"", 1, false},
+		{"page=", 1, false},
+		{"page=0", 1, false},
+		{"page=1", 1, false},
+		{"page=7", 7, false},
+		{"page=1073741823", 1<<30 - 1, false},
+		{"page=1073741824", 0, true},
+		{"page=-1", 0, true},
+		{"page=abc", 0, true},
+		{"page=1.5", 0, true},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			page, err := ParsePageQueryParam(queryContext(tc.query))
+			if (err != nil) != tc.wantErr || page != tc.page {
+				t.Fatalf("got %d, %v, want %d, error %v", page, err, tc.page, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseLimitQueryParam(t *testing.T) {
+	for _, tc := range []struct {
+		query   string
+		limit   uint64
+		wantErr bool
+	}{
+		{"", 10, false},
+		{"limit=", 10, false},
+		{"limit=0", 10, false},
+		{"limit=1", 1, false},
+		{"limit=100", 100, false},
+		{"limit=1073741824", 0, true},
+		{"limit=-5", 0, true},
+		{"limit=ten", 0, true},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			limit, err := ParseLimitQueryParam(queryContext(tc.query))
+			if (err != nil) != tc.wantErr || limit != tc.limit {
+				t.Fatalf("got %d, %v, want %d, error %v", limit, err, tc.limit, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"api_gateway/api/models"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// readinessTimeout bounds the probes of all dependencies.
+	readinessTimeout = 2 * time.Second
+
+	statusUp       = "up"
+	statusDown     = "down"
+	statusReady    = "ready"
+	statusNotReady = "not_ready"
+)
+
+// Healthz godoc
+// @Router       /healthz [GET]
+// @Summary      Liveness probe
+// @Description  Reports that the gateway process is alive, dependencies are not checked
+// @Tags         health
+// @Produce      json
+// @Success		 200  {object}  models.ResponseResult
+func (h *handler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, models.ResponseResult{Result: "ok"})
+}
+
+// Readyz godoc
+// @Router       /readyz [GET]
+// @Summary      Readiness probe
+// @Description  Checks the user and task services and Redis when configured
+// @Tags         health
+// @Produce      json
+// @Success		 200  {object}  models.ReadinessResponse
+// @Failure		 503  {object}  models.ReadinessResponse
+func (h *handler) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	resp := models.ReadinessResponse{
+		Status:       statusReady,
+		Dependencies: make(map[string]models.DependencyStatus),
+	}
+	report := func(name, state string, err error) {
+		dep := models.DependencyStatus{Status: statusUp, State: state}
+		if err != nil {
+			dep.Status = statusDown
+			dep.Error = err.Error()
+			resp.Status = statusNotReady
+		}
+		resp.Dependencies[name] = dep
+	}
+
+	for service, health := range h.grpcClient.CheckHealth(ctx) {
+		report(service, health.State, health.Err)
+	}
+	if h.redis != nil {
+		report("redis", "", h.redis.Ping(ctx).Err())
+	}
+
+	code := http.StatusOK
+	if resp.Status != statusReady {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, resp)
+}
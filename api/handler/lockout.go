@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"api_gateway/api/models"
+	"api_gateway/pkg/lockout"
+	"api_gateway/pkg/logger"
+	"api_gateway/pkg/metrics"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	loginAccountUser  = "user"
+	loginAccountAdmin = "admin"
+)
+
+// loginAttempt is a login reserved on the lockout keys of its login name and client address.
+type loginAttempt struct {
+	account  string
+	keys     []string
+	policies []lockout.Policy
+}
+
+// reserveLoginAttempt counts the login as failed on its login name and its
+// client address before it is made, so parallel logins cannot slip past the
+// lockout. It aborts with 423 when one of them is locked out and with 429
+// when its next attempt has to wait or too many of its logins are pending.
+// It reports whether the login may go on, the attempt must then be settled.
+func (h *handler) reserveLoginAttempt(c *gin.Context, account, login string) (*loginAttempt, bool) {
+	attempt := &loginAttempt{account: account}
+	if h.lockouts == nil {
+		return attempt, true
+	}
+
+	policies := []lockout.Policy{h.loginLockoutPolicy(), h.ipLockoutPolicy()}
+	for i, key := range loginKeys(c, account, login) {
+		e, ok, err := h.lockouts.Reserve(c.Request.Context(), key, policies[i])
+		if err != nil {
+			// fail open, an unavailable store must not block every login
+			h.logFor(c).Error("error while reserving login attempt", logger.String("key", key), logger.Error(err))
+			continue
+		}
+		if !ok {
+			h.settle(c, attempt, lockout.Refunded)
+			metrics.LoginAttempt(account, metrics.LoginLocked)
+
+			now := time.Now()
+			switch {
+			case e.Locked(now):
+				abortWithLockout(c, http.StatusLocked, "too many failed logins, locked until unlock_at", e.LockedUntil)
+			case e.Delayed(now):
+				abortWithLockout(c, http.StatusTooManyRequests, "too many failed logins, retry after retry_after seconds", e.NextAttempt)
+			default:
+				abortWithLockout(c, http.StatusTooManyRequests, "too many pending logins, retry after retry_after seconds", now.Add(time.Second))
+			}
+			return nil, false
+		}
+		attempt.keys = append(attempt.keys, key)
+		attempt.policies = append(attempt.policies, policies[i])
+	}
+	return attempt, true
+}
+
+// settleLoginAttempt ends a login reserved by reserveLoginAttempt. A
+// successful login forgets the failures of the login name and refunds the
+// one of the client address, a rejected one keeps both. Errors of an
+// unavailable backend are not the caller's fault and are refunded.
+func (h *handler) settleLoginAttempt(c *gin.Context, attempt *loginAttempt, err error) {
+	result := loginResult(err)
+	metrics.LoginAttempt(attempt.account, result)
+
+	switch result {
+	case metrics.LoginSuccess:
+		h.settle(c, attempt, lockout.Succeeded)
+	case metrics.LoginError:
+		h.settle(c, attempt, lockout.Refunded)
+	default:
+		h.settle(c, attempt, lockout.Failed)
+	}
+}
+
+// settle ends the reservations of attempt with outcome. Succeeded applies to
+// the login name only, the client address is refunded.
+func (h *handler) settle(c *gin.Context, attempt *loginAttempt, outcome lockout.Outcome) {
+	// the reservations must end even when the client went away
+	ctx := context.WithoutCancel(c.Request.Context())
+
+	for i, key := range attempt.keys {
+		keyOutcome := outcome
+		if outcome == lockout.Succeeded && i > 0 {
+			keyOutcome = lockout.Refunded
+		}
+
+		e, err := h.lockouts.Settle(ctx, key, attempt.policies[i], keyOutcome)
+		if err != nil {
+			h.logFor(c).Error("error while settling login attempt", logger.String("key", key), logger.Error(err))
+			continue
+		}
+		if keyOutcome == lockout.Failed && e.Locked(time.Now()) {
+			h.logFor(c).Warn("login locked out",
+				logger.String("key", key),
+				logger.Int("failures", e.Failures),
+				logger.Any("locked_until", e.LockedUntil))
+		}
+	}
+}
+
+// loginResult classifies the result of a login call, see metrics.Login*.
+func loginResult(err error) string {
+	switch status.Code(err) {
+	case codes.OK:
+		return metrics.LoginSuccess
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled, codes.ResourceExhausted:
+		return metrics.LoginError
+	}
+	return metrics.LoginFailure
+}
+
+// loginLockoutPolicy throttles the failed logins of one login name.
+func (h *handler) loginLockoutPolicy() lockout.Policy {
+	return lockout.Policy{
+		MaxFailures: h.cfg.LoginMaxFailures,
+		Duration:    h.cfg.LoginLockoutDuration,
+		BaseDelay:   h.cfg.LoginDelayBase,
+		MaxDelay:    h.cfg.LoginDelayMax,
+		Window:      h.cfg.LoginFailureWindow,
+		MaxInFlight: h.cfg.LoginMaxInFlight,
+	}
+}
+
+// ipLockoutPolicy throttles the failed logins from one client address. It has
+// no delays, many users may share an address behind NAT.
+func (h *handler) ipLockoutPolicy() lockout.Policy {
+	return lockout.Policy{
+		MaxFailures: h.cfg.LoginIPMaxFailures,
+		Duration:    h.cfg.LoginLockoutDuration,
+		Window:      h.cfg.LoginFailureWindow,
+		MaxInFlight: h.cfg.LoginIPMaxInFlight,
+	}
+}
+
+// loginKeys returns the lockout keys of a login attempt: the login name first, then the client address.
+func loginKeys(c *gin.Context, account, login string) []string {
+	return []string{
+		account + ":" + strings.ToLower(strings.TrimSpace(login)),
+		"ip:" + c.ClientIP(),
+	}
+}
+
+func abortWithLockout(c *gin.Context, code int, description string, until time.Time) {
+	retryAfter := ceilSeconds(time.Until(until))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(code, models.LockoutError{
+		Problem:    newProblem(c, code, statusErrorCodes[code], description),
+		UnlockAt:   until.UTC(),
+		RetryAfter: retryAfter,
+	})
+}
+
+// @Security ApiKeyAuth
+// @Param   Authorization  header  string  true  "Authorization token"
+// @Router /v1/admin/lockouts [GET]
+// @Summary Get login lockouts
+// @Description API for listing login names and client addresses with recent failed logins
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Success		200  {array}   lockout.Entry
+// @Failure		401  {object}  models.Problem
+// @Failure		403  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
+func (h *handler) GetLockouts(c *gin.Context) {
+	entries := []lockout.Entry{}
+	if h.lockouts != nil {
+		list, err := h.lockouts.List(c.Request.Context())
+		if err != nil {
+			h.logFor(c).Error("error while listing lockouts", logger.Error(err))
+			abortWithStatus(c, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		entries = append(entries, list...)
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// @Security ApiKeyAuth
+// @Param   Authorization  header  string  true  "Authorization token"
+// @Router /v1/admin/lockouts/{key} [DELETE]
+// @Summary Clear login lockout
+// @Description API for clearing the failed logins of a key, e.g. user:john or ip:10.0.0.1
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param 		key path string true "key"
+// @Success		200  {object}  models.ResponseOK
+// @Failure		401  {object}  models.Problem
+// @Failure		403  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
+func (h *handler) ClearLockout(c *gin.Context) {
+	key := c.Param("key")
+
+	found := false
+	if h.lockouts != nil {
+		var err error
+		found, err = h.lockouts.Reset(c.Request.Context(), key)
+		if err != nil {
+			h.logFor(c).Error("error while clearing lockout", logger.String("key", key), logger.Error(err))
+			abortWithStatus(c, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+	}
+	if !found {
+		abortWithStatus(c, http.StatusNotFound, "no failed logins for key")
+		return
+	}
+
+	h.logFor(c).Info("lockout cleared", logger.String("key", key), logger.String("by", getAuthInfo(c).UserID))
+	c.JSON(http.StatusOK, models.ResponseOK{Message: "lockout cleared"})
+}
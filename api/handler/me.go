@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"api_gateway/api/models"
+	"api_gateway/config"
+	"api_gateway/genproto/admin_service"
+	"api_gateway/genproto/task_service"
+	"api_gateway/genproto/user_service"
+	"api_gateway/pkg/validator"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isAdminAccount reports whether the caller is stored in the admin service rather than the user service.
+func isAdminAccount(authInfo models.AuthInfo) bool {
+	return authInfo.UserRole == config.ADMIN_ROLE || authInfo.UserRole == config.SUPERADMIN_ROLE
+}
+
+// @Security ApiKeyAuth
+// @Param   Authorization  header  string  true  "Authorization token"
+// @Router /v1/me [GET]
+// @Summary Get me
+// @Description API for getting the caller identity from the token
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Success		200  {object}  models.AuthInfo
+// @Failure		401  {object}  models.Problem
+func (h *handler) GetMe(c *gin.Context) {
+	c.JSON(http.StatusOK, getAuthInfo(c))
+}
+
+// @Security ApiKeyAuth
+// @Param   Authorization  header  string  true  "Authorization token"
+// @Router /v1/me/profile [GET]
+// @Summary Get my profile
+// @Description API for getting the profile of the caller
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Success		200  {object}  models.ResponseSuccess
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
+func (h *handler) GetMyProfile(c *gin.Context) {
+	authInfo := getAuthInfo(c)
+
+	if isAdminAccount(authInfo) {
+		resp, err := h.grpcClient.AdminService().GetByID(c.Request.Context(), &admin_service.AdminPrimaryKey{Id: authInfo.UserID})
+		if err != nil {
+			handleGrpcErrWithDescription(c, h.logFor(c), err, "error while getting admin")
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	resp, err := h.grpcClient.UserService().GetByID(c.Request.Context(), &user_service.UserPrimaryKey{Id: authInfo.UserID})
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while getting user")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Security ApiKeyAuth
+// @Param   Authorization  header  string  true  "Authorization token"
+// @Router /v1/me/profile [PUT]
+// @Summary Update my profile
+// @Description API for updating the profile of the caller
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param		profile body  models.UpdateProfileRequest true "profile"
+// @Success		200  {object}  models.ResponseSuccess
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
+func (h *handler) UpdateMyProfile(c *gin.Context) {
+	authInfo := getAuthInfo(c)
+
+	profile := &models.UpdateProfileRequest{}
+	if err := c.ShouldBindJSON(profile); err != nil {
+		abortWithInvalidJSON(c, err)
+		return
+	}
+	if !validator.ValidateGmail(profile.Email) {
+		abortWithViolation(c, "wrong gmail", "email", "must be a gmail.com address")
+		return
+	}
+	if !validator.ValidatePhone(profile.Phone) {
+		abortWithViolation(c, "wrong phone", "phone", "must be +998 followed by 9 digits")
+		return
+	}
+
+	if isAdminAccount(authInfo) {
+		resp, err := h.grpcClient.AdminService().Update(c.Request.Context(), &admin_service.UpdateAdmin{
+			Id:       authInfo.UserID,
+			Birthday: profile.Birthday,
+			Gender:   profile.Gender,
+			Fullname: profile.Fullname,
+			Email:    profile.Email,
+			Phone:    profile.Phone,
+		})
+		if err != nil {
+			handleGrpcErrWithDescription(c, h.logFor(c), err, "error while updating admin")
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	if err := validator.ValidateBitrthday(profile.Birthday); err != nil {
+		abortWithViolation(c, "wrong birthday", "birthday", err.Error())
+		return
+	}
+	resp, err := h.grpcClient.UserService().Update(c.Request.Context(), &user_service.UpdateUser{
+		Id:       authInfo.UserID,
+		Birthday: profile.Birthday,
+		Gender:   profile.Gender,
+		Fullname: profile.Fullname,
+		Email:    profile.Email,
+		Phone:    profile.Phone,
+	})
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while updating user")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Security ApiKeyAuth
+// @Param   Authorization  header  string  true  "Authorization token"
+// @Router /v1/me/password [PUT]
+// @Summary Change my password
+// @Description API for changing the password of the caller
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param		password body  models.ChangePasswordRequest true "password"
+// @Success		200  {object}  models.ResponseSuccess
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
+func (h *handler) ChangeMyPassword(c *gin.Context) {
+	authInfo := getAuthInfo(c)
+
+	req := &models.ChangePasswordRequest{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		abortWithInvalidJSON(c, err)
+		return
+	}
+	if err := validator.ValidatePassword(req.NewPassword); err != nil {
+		abortWithViolation(c, "wrong password", "new_password", err.Error())
+		return
+	}
+
+	if isAdminAccount(authInfo) {
+		admin, err := h.grpcClient.AdminService().GetByID(c.Request.Context(), &admin_service.AdminPrimaryKey{Id: authInfo.UserID})
+		if err != nil {
+			handleGrpcErrWithDescription(c, h.logFor(c), err, "error while getting admin")
+			return
+		}
+		resp, err := h.grpcClient.AdminService().ChangePassword(c.Request.Context(), &admin_service.AdminChangePassword{
+			UserLogin:   admin.UserLogin,
+			OldPassword: req.OldPassword,
+			NewPassword: req.NewPassword,
+		})
+		if err != nil {
+			handleGrpcErrWithDescription(c, h.logFor(c), err, "error while changing admin's password")
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	user, err := h.grpcClient.UserService().GetByID(c.Request.Context(), &user_service.UserPrimaryKey{Id: authInfo.UserID})
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while getting user")
+		return
+	}
+	resp, err := h.grpcClient.UserService().ChangePassword(c.Request.Context(), &user_service.UserChangePassword{
+		UserLogin:   user.UserLogin,
+		OldPassword: req.OldPassword,
+		NewPassword: req.NewPassword,
+	})
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while changing user's password")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Security ApiKeyAuth
+// @Param   Authorization  header  string  true  "Authorization token"
+// @Router /v1/me/tasks [GET]
+// @Summary Get my tasks
+// @Description API for getting tasks owned by the caller
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param		search query string false "search"
+// @Param		page query int false "page"
+// @Param		limit query int false "limit"
+// @Success		200  {object}  models.ResponseSuccess
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
+func (h *handler) GetMyTasks(c *gin.Context) {
+	authInfo := getAuthInfo(c)
+
+	page, err := ParsePageQueryParam(c)
+	if err != nil {
+		abortWithViolation(c, "invalid page", "page", "must be a non-negative integer")
+		return
+	}
+	limit, err := ParseLimitQueryParam(c)
+	if err != nil {
+		abortWithViolation(c, "invalid limit", "limit", "must be a non-negative integer")
+		return
+	}
+
+	resp, err := h.grpcClient.TaskService().GetList(c.Request.Context(), &task_service.GetListTaskRequest{
+		Search:  c.Query("search"),
+		Offset:  int64(page),
+		Limit:   int64(limit),
+		OwnerId: authInfo.UserID,
+	})
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while getting tasks")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"api_gateway/pkg/grpc_client"
+	"api_gateway/pkg/logger"
+	"api_gateway/pkg/metrics"
+	"api_gateway/pkg/requestid"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const authInfoKey = "auth_info"
+
+// RequestID reuses the X-Request-ID of the client or generates one, echoes it
+// in the response and puts it in the request context, where the logs of the
+// request and the gRPC calls made for it pick it up.
+func (h *handler) RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		c.Header(requestid.Header, id)
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// quietRoutes are polled by probes and scrapers, their access log lines are debug.
+var quietRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// AccessLog logs every request once it is handled, with its route template,
+// status, latency, caller and request ID. Server errors log at error level.
+func (h *handler) AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = metrics.UnmatchedRoute
+		}
+		fields := []logger.Field{
+			logger.String("method", c.Request.Method),
+			logger.String("route", route),
+			logger.String("path", c.Request.URL.Path),
+			logger.Int("status", c.Writer.Status()),
+			logger.Duration("latency", time.Since(start)),
+			logger.String("client_ip", c.ClientIP()),
+		}
+		if userID := getAuthInfo(c).UserID; userID != "" {
+			fields = append(fields, logger.String("user_id", userID))
+		}
+
+		log := h.logFor(c)
+		switch {
+		case c.Writer.Status() >= http.StatusInternalServerError:
+			log.Error("request", fields...)
+		case quietRoutes[route]:
+			log.Debug("request", fields...)
+		default:
+			log.Info("request", fields...)
+		}
+	}
+}
+
+// Metrics counts the requests and observes their latency per route template,
+// so "/v1/task/get/:id" is one series whatever the ID.
+func (h *handler) Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		done := metrics.HTTPRequestStarted(c.Request.Method, c.FullPath())
+		c.Next()
+		done(c.Writer.Status())
+	}
+}
+
+// AuthMiddleware validates the access token from the Authorization header,
+// checks it against the revocation store, stores models.AuthInfo in the
+// gin context and aborts with 401 otherwise.
+func (h *handler) AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authInfo, err := parseAuthInfo(c.GetHeader("Authorization"))
+		if err != nil {
+			h.logFor(c).Warn("unauthorized request", logger.String("path", c.Request.URL.Path), logger.Error(err))
+			metrics.AuthFailure(metrics.AuthFailureInvalidToken)
+			abortWithStatus(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		revoked, err := h.isRevoked(c.Request.Context(), authInfo.TokenID, authInfo.UserID, authInfo.IssuedAt)
+		if err != nil {
+			h.logFor(c).Error("error while checking token revocation", logger.Error(err))
+			abortWithStatus(c, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		if revoked {
+			metrics.AuthFailure(metrics.AuthFailureRevokedToken)
+			abortWithStatus(c, http.StatusUnauthorized, "token has been revoked")
+			return
+		}
+
+		c.Set(authInfoKey, authInfo)
+		c.Next()
+	}
+}
+
+// Authorize enforces the access policy on secured routes. It must run after AuthMiddleware.
+func (h *handler) Authorize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authInfo := getAuthInfo(c)
+
+		if !h.policy.Allowed(authInfo.UserRole, c.Request.Method, c.FullPath()) {
+			h.logFor(c).Warn("forbidden request",
+				logger.String("path", c.FullPath()),
+				logger.String("user_id", authInfo.UserID),
+				logger.String("user_role", authInfo.UserRole))
+			metrics.AuthFailure(metrics.AuthFailureForbidden)
+			abortWithStatus(c, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RetryBudget limits the retries of the gRPC calls made while handling a request.
+func (h *handler) RetryBudget() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := grpc_client.WithRetryBudget(c.Request.Context(), h.cfg.GrpcRetryBudget)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// isRevoked reports whether the token was logged out on its own or by a logout-all of its user.
+func (h *handler) isRevoked(ctx context.Context, tokenID, userID string, issuedAt time.Time) (bool, error) {
+	revoked, err := h.tokenStore.IsRevoked(ctx, tokenID)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	cutoff, err := h.tokenStore.IssuedBefore(ctx, userID)
+	if err != nil || cutoff.IsZero() {
+		return false, err
+	}
+	if issuedAt.Equal(issuedAt.Truncate(time.Second)) {
+		// a whole-second iat, as the backend services issue, may be anywhere in
+		// its second: tokens of the second of the cutoff may predate it
+		return !issuedAt.After(cutoff), nil
+	}
+	return issuedAt.Before(cutoff), nil
+}
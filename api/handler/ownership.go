@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"api_gateway/api/models"
+	"api_gateway/genproto/admin_service"
+	"api_gateway/genproto/task_service"
+	"api_gateway/genproto/user_service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Capabilities of the access policy that let a caller act on resources of other users.
+const (
+	actionManageAnyTask  = "task.manage_any"
+	actionManageAnyUser  = "user.manage_any"
+	actionManageAnyAdmin = "admin.manage_any"
+)
+
+func (h *handler) canManageAny(authInfo models.AuthInfo, action string) bool {
+	return h.policy.Can(authInfo.UserRole, action)
+}
+
+// ownTask loads the task and makes sure the caller may act on it.
+// On failure the response is written and ok is false.
+func (h *handler) ownTask(c *gin.Context, id string) (task *task_service.GetTask, ok bool) {
+	task, err := h.grpcClient.TaskService().GetByID(c.Request.Context(), &task_service.TaskPrimaryKey{Id: id})
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while getting task")
+		return nil, false
+	}
+	return task, h.checkTaskOwner(c, task)
+}
+
+// checkTaskOwner writes 403 and returns false when the task belongs to another user
+// and the caller has no right to manage tasks of others.
+func (h *handler) checkTaskOwner(c *gin.Context, task *task_service.GetTask) bool {
+	authInfo := getAuthInfo(c)
+	if h.canManageAny(authInfo, actionManageAnyTask) || task.UserId == authInfo.UserID {
+		return true
+	}
+	abortWithStatus(c, http.StatusForbidden, "task belongs to another user")
+	return false
+}
+
+// checkSelf writes 403 and returns false when id is not the caller
+// and the caller has no right to manage profiles of others.
+func (h *handler) checkSelf(c *gin.Context, id, action string) bool {
+	authInfo := getAuthInfo(c)
+	if h.canManageAny(authInfo, action) || id == authInfo.UserID {
+		return true
+	}
+	abortWithStatus(c, http.StatusForbidden, "only your own profile is accessible")
+	return false
+}
+
+// checkUserLogin is checkSelf for requests that identify the user by login.
+func (h *handler) checkUserLogin(c *gin.Context, login string) bool {
+	authInfo := getAuthInfo(c)
+	if h.canManageAny(authInfo, actionManageAnyUser) {
+		return true
+	}
+
+	user, err := h.grpcClient.UserService().GetByID(c.Request.Context(), &user_service.UserPrimaryKey{Id: authInfo.UserID})
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while getting user")
+		return false
+	}
+	if user.UserLogin != login {
+		abortWithStatus(c, http.StatusForbidden, "only your own profile is accessible")
+		return false
+	}
+	return true
+}
+
+// checkAdminLogin is checkSelf for requests that identify the admin by login.
+func (h *handler) checkAdminLogin(c *gin.Context, login string) bool {
+	authInfo := getAuthInfo(c)
+	if h.canManageAny(authInfo, actionManageAnyAdmin) {
+		return true
+	}
+
+	admin, err := h.grpcClient.AdminService().GetByID(c.Request.Context(), &admin_service.AdminPrimaryKey{Id: authInfo.UserID})
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while getting admin")
+		return false
+	}
+	if admin.UserLogin != login {
+		abortWithStatus(c, http.StatusForbidden, "only your own profile is accessible")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"api_gateway/api/models"
+	"api_gateway/genproto/task_service"
+	"api_gateway/genproto/user_service"
+	"api_gateway/pkg/grpc_client"
+	"api_gateway/pkg/grpc_client/fake"
+	"api_gateway/pkg/rbac"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	owner    = models.AuthInfo{UserID: "owner", UserRole: "user"}
+	stranger = models.AuthInfo{UserID: "stranger", UserRole: "user"}
+	admin    = models.AuthInfo{UserID: "admin", UserRole: "admin"}
+)
+
+func fakeHandler(client *fake.Client) *handler {
+	return New(&HandlerConfig{
+		Logger:     testLog,
+		GrpcClient: client,
+		Policy:     rbac.Default(),
+	})
+}
+
+// serve calls handle as the caller, params are the path parameters as name, value pairs.
+func serve(handle gin.HandlerFunc, caller models.AuthInfo, method, target, body string, params ...string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	c.Set(authInfoKey, caller)
+	for i := 0; i+1 < len(params); i += 2 {
+		c.Params = append(c.Params, gin.Param{Key: params[i], Value: params[i+1]})
+	}
+	handle(c)
+	return w
+}
+
+// ownedTasks returns a client whose task service has one task of owner.
+func ownedTasks() *fake.Client {
+	client := fake.New()
+	client.Task.GetByIDFunc = func(_ context.Context, in *task_service.TaskPrimaryKey) (*task_service.GetTask, error) {
+		if in.Id != "task-1" {
+			return nil, status.Errorf(codes.NotFound, "task %q not found", in.Id)
+		}
+		return &task_service.GetTask{Id: in.Id, UserId: owner.UserID}, nil
+	}
+	client.Task.DeleteFunc = func(context.Context, *task_service.TaskPrimaryKey) (*empty.Empty, error) {
+		return &empty.Empty{}, nil
+	}
+	client.Task.ChangeStatusFunc = func(context.Context, *task_service.TaskChangeStatus) (*task_service.TaskChangeStatusResp, error) {
+		return &task_service.TaskChangeStatusResp{}, nil
+	}
+	return client
+}
+
+func TestTaskOwnership(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		caller models.AuthInfo
+		status int
+	}{
+		{"owner", owner, http.StatusOK},
+		{"admin", admin, http.StatusOK},
+		{"other user", stranger, http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := ownedTasks()
+			h := fakeHandler(client)
+
+			if w := serve(h.DeleteTask, tc.caller, http.MethodDelete, "/v1/task/delete/task-1", "", "id", "task-1"); w.Code != tc.status {
+				t.Fatalf("delete: status = %d, want %d, body: %s", w.Code, tc.status, w.Body.String())
+			}
+			w := serve(h.TaskChangeStatus, tc.caller, http.MethodPatch, "/v1/task/change_status", `{"task_id":"task-1","new_status":"done"}`)
+			if w.Code != tc.status {
+				t.Fatalf("change status: status = %d, want %d, body: %s", w.Code, tc.status, w.Body.String())
+			}
+
+			// the task service is asked to change the task only when the caller may
+			want := 0
+			if tc.status == http.StatusOK {
+				want = 1
+			}
+			if n := len(client.Task.Called("Delete")); n != want {
+				t.Fatalf("Delete called %d times, want %d", n, want)
+			}
+			if n := len(client.Task.Called("ChangeStatus")); n != want {
+				t.Fatalf("ChangeStatus called %d times, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestTaskOwnershipOfMissingTask(t *testing.T) {
+	client := ownedTasks()
+
+	w := serve(fakeHandler(client).DeleteTask, owner, http.MethodDelete, "/v1/task/delete/task-2", "", "id", "task-2")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404, body: %s", w.Code, w.Body.String())
+	}
+	if calls := client.Task.Calls(); len(calls) != 1 || calls[0].Method != "GetByID" {
+		t.Fatalf("calls = %+v, want GetByID only", calls)
+	}
+}
+
+func TestGetAllTaskScopesUsersToTheirOwnTasks(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		caller models.AuthInfo
+		owner  string
+	}{
+		{"user", stranger, stranger.UserID},
+		{"admin", admin, owner.UserID},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fake.New()
+			client.Task.GetListFunc = func(context.Context, *task_service.GetListTaskRequest) (*task_service.GetListTaskResponse, error) {
+				return &task_service.GetListTaskResponse{}, nil
+			}
+
+			w := serve(fakeHandler(client).GetAllTask, tc.caller, http.MethodGet, "/v1/task/getall?user_id=owner&search=milk&page=3&limit=20", "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+			}
+
+			requests := client.Task.Called("GetList")
+			if len(requests) != 1 {
+				t.Fatalf("GetList called %d times, want 1", len(requests))
+			}
+			req := requests[0].(*task_service.GetListTaskRequest)
+			if req.OwnerId != tc.owner || req.Search != "milk" || req.Offset != 3 || req.Limit != 20 {
+				t.Fatalf("request = %v, want owner %q", req, tc.owner)
+			}
+		})
+	}
+}
+
+func TestCheckUserLogin(t *testing.T) {
+	client := fake.New()
+	client.User.GetByIDFunc = func(_ context.Context, in *user_service.UserPrimaryKey) (*user_service.GetUser, error) {
+		return &user_service.GetUser{Id: in.Id, UserLogin: in.Id + "-login"}, nil
+	}
+	h := fakeHandler(client)
+
+	for _, tc := range []struct {
+		caller models.AuthInfo
+		login  string
+		ok     bool
+	}{
+		{owner, "owner-login", true},
+		{owner, "stranger-login", false},
+		{admin, "stranger-login", true},
+	} {
+		var ok bool
+		w := serve(func(c *gin.Context) { ok = h.checkUserLogin(c, tc.login) }, tc.caller, http.MethodPut, "/", "")
+		if ok != tc.ok || (!ok && w.Code != http.StatusForbidden) {
+			t.Fatalf("%s acting on %s: ok = %v, status %d", tc.caller.UserID, tc.login, ok, w.Code)
+		}
+	}
+	// admins are not looked up
+	if n := len(client.User.Called("GetByID")); n != 2 {
+		t.Fatalf("GetByID called %d times, want 2", n)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	client := fake.New()
+	h := fakeHandler(client)
+
+	if w := serve(h.Readyz, models.AuthInfo{}, http.MethodGet, "/readyz", ""); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+	}
+
+	client.Health = map[string]grpc_client.Health{
+		grpc_client.UserServiceName: {State: "READY"},
+		grpc_client.TaskServiceName: {State: "TRANSIENT_FAILURE", Err: errors.New("connection refused")},
+	}
+	w := serve(h.Readyz, models.AuthInfo{}, http.MethodGet, "/readyz", "")
+	var resp models.ReadinessResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	task := resp.Dependencies[grpc_client.TaskServiceName]
+	if w.Code != http.StatusServiceUnavailable || resp.Status != statusNotReady ||
+		task.Status != statusDown || task.Error != "connection refused" ||
+		resp.Dependencies[grpc_client.UserServiceName].Status != statusUp {
+		t.Fatalf("got %d %+v", w.Code, resp)
+	}
+}
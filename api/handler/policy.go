@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Security ApiKeyAuth
+// @Param   Authorization  header  string  true  "Authorization token"
+// @Router /v1/admin/policy [GET]
+// @Summary Get access policy
+// @Description API for inspecting the active role-based access rules
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Success		200  {object}  rbac.Policy
+// @Failure		401  {object}  models.Problem
+// @Failure		403  {object}  models.Problem
+func (h *handler) GetPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, h.policy)
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"api_gateway/pkg/logger"
+	"api_gateway/pkg/metrics"
+	"api_gateway/pkg/ratelimit"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit limits the requests of a route group with a token bucket per
+// client, identified by keyBy (see ratelimit.KeyBy*). spec is a limit in
+// ratelimit.ParseLimit format. Every response carries the X-RateLimit-*
+// headers, rejected ones also Retry-After.
+//
+// On secured groups it must run after AuthMiddleware to key by user.
+func (h *handler) RateLimit(group, spec, keyBy string) gin.HandlerFunc {
+	limit, err := ratelimit.ParseLimit(spec)
+	if err != nil {
+		h.log.Error("invalid rate limit, group is not limited", logger.String("group", group), logger.Error(err))
+	}
+	if h.limiter == nil || limit.Unlimited() {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		key := group + ":" + h.rateLimitKey(c, keyBy)
+
+		res, err := h.limiter.Allow(c.Request.Context(), key, limit)
+		if err != nil {
+			// fail open, an unavailable limiter must not take the gateway down
+			h.logFor(c).Error("error while checking rate limit", logger.String("group", group), logger.Error(err))
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+
+		if !res.Allowed {
+			h.logFor(c).Warn("rate limit exceeded", logger.String("group", group), logger.String("key", key))
+			metrics.RateLimitRejection(group)
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			abortWithStatus(c, http.StatusTooManyRequests, "Too Many Requests")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitKey identifies the client of the request by allow-listed API key or
+// user ID, falling back to the client address. Only authenticated identities,
+// known API keys and addresses c.ClientIP() can vouch for are used, a key the
+// client may pick freely would give it a fresh bucket per request.
+func (h *handler) rateLimitKey(c *gin.Context, keyBy string) string {
+	if keyBy == ratelimit.KeyByAPIKey {
+		if apiKey := c.GetHeader(h.cfg.RateLimitAPIKeyHeader); apiKey != "" {
+			// never keep raw API keys in limiter keys and logs
+			if hash := ratelimit.HashAPIKey(apiKey); h.apiKeys[hash] {
+				return "key:" + hash[:16]
+			}
+		}
+	}
+
+	if keyBy == ratelimit.KeyByUser {
+		if userID := getAuthInfo(c).UserID; userID != "" {
+			return "user:" + userID
+		}
+	}
+
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"api_gateway/genproto/task_service"
-	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -16,52 +15,46 @@ import (
 // @Tags task
 // @Accept  json
 // @Produce  json
-// @Param		user_id query string true "user_id"
+// @Param		user_id query string false "owner, honoured for admins only"
 // @Param		search query string false "search"
 // @Param		page query int false "page"
 // @Param		limit query int false "limit"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) GetAllTask(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
+	authInfo := getAuthInfo(c)
+	task := &task_service.GetListTaskRequest{}
 
+	search := c.Query("search")
+	user_id := authInfo.UserID
+	if h.canManageAny(authInfo, actionManageAnyTask) {
+		user_id = c.Query("user_id")
 	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" || authInfo.UserRole == "user" {
-
-		task := &task_service.GetListTaskRequest{}
 
-		search := c.Query("search")
-		user_id := c.Query("user_id")
-
-		page, err := ParsePageQueryParam(c)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while parsing page")
-			return
-		}
-		limit, err := ParseLimitQueryParam(c)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while parsing limit")
-			return
-		}
+	page, err := ParsePageQueryParam(c)
+	if err != nil {
+		abortWithViolation(c, "invalid page", "page", "must be a non-negative integer")
+		return
+	}
+	limit, err := ParseLimitQueryParam(c)
+	if err != nil {
+		abortWithViolation(c, "invalid limit", "limit", "must be a non-negative integer")
+		return
+	}
 
-		task.Search = search
-		task.Offset = int64(page)
-		task.Limit = int64(limit)
-		task.OwnerId = user_id
+	task.Search = search
+	task.Offset = int64(page)
+	task.Limit = int64(limit)
+	task.OwnerId = user_id
 
-		resp, err := h.grpcClient.TaskService().GetList(c.Request.Context(), task)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while creating task")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins can change task")
+	resp, err := h.grpcClient.TaskService().GetList(c.Request.Context(), task)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while creating task")
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -74,32 +67,26 @@ func (h *handler) GetAllTask(c *gin.Context) {
 // @Produce  json
 // @Param		task body  task_service.CreateTask true "task"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) CreateTask(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
+	authInfo := getAuthInfo(c)
+	task := &task_service.CreateTask{}
+	if err := c.ShouldBindJSON(&task); err != nil {
+		abortWithInvalidJSON(c, err)
+		return
+	}
+	if task.UserId == "" || !h.canManageAny(authInfo, actionManageAnyTask) {
+		task.UserId = authInfo.UserID
 	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" || authInfo.UserRole == "user" {
-
-		task := &task_service.CreateTask{}
-		if err := c.ShouldBindJSON(&task); err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while reading body")
-			return
-		}
 
-		resp, err := h.grpcClient.TaskService().Create(c.Request.Context(), task)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while creating task")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins can change task")
+	resp, err := h.grpcClient.TaskService().Create(c.Request.Context(), task)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while creating task")
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -112,32 +99,29 @@ func (h *handler) CreateTask(c *gin.Context) {
 // @Produce  json
 // @Param		task body  task_service.UpdateTask true "task"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) UpdateTask(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
+	task := &task_service.UpdateTask{}
+	if err := c.ShouldBindJSON(&task); err != nil {
+		abortWithInvalidJSON(c, err)
+		return
 	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" || authInfo.UserRole == "user" {
 
-		task := &task_service.UpdateTask{}
-		if err := c.ShouldBindJSON(&task); err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while reading body")
-			return
-		}
+	current, ok := h.ownTask(c, task.Id)
+	if !ok {
+		return
+	}
+	// the owner is not changed through update
+	task.UserId = current.UserId
 
-		resp, err := h.grpcClient.TaskService().Update(c.Request.Context(), task)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while updating task")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins and task can change task")
+	resp, err := h.grpcClient.TaskService().Update(c.Request.Context(), task)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while updating task")
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -150,29 +134,17 @@ func (h *handler) UpdateTask(c *gin.Context) {
 // @Produce  json
 // @Param 		id path string true "id"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) GetTaskById(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
-	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" || authInfo.UserRole == "user" {
+	id := c.Param("id")
 
-		id := c.Param("id")
-		task := &task_service.TaskPrimaryKey{Id: id}
-
-		resp, err := h.grpcClient.TaskService().GetByID(c.Request.Context(), task)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while getting task")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins and tasks can change task")
+	resp, ok := h.ownTask(c, id)
+	if !ok {
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -185,29 +157,22 @@ func (h *handler) GetTaskById(c *gin.Context) {
 // @Produce  json
 // @Param 		id path string true "id"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) GetByExternalId(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
+	id := c.Param("id")
+	task := &task_service.TaskPrimaryKey{Id: id}
 
+	resp, err := h.grpcClient.TaskService().GetByExternalId(c.Request.Context(), task)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while getting task")
+		return
 	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" || authInfo.UserRole == "user" {
-
-		id := c.Param("id")
-		task := &task_service.TaskPrimaryKey{Id: id}
-
-		resp, err := h.grpcClient.TaskService().GetByExternalId(c.Request.Context(), task)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while getting task")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins and tasks can change task")
+	if !h.checkTaskOwner(c, resp) {
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -220,29 +185,23 @@ func (h *handler) GetByExternalId(c *gin.Context) {
 // @Produce  json
 // @Param 		id path string true "id"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) DeleteTask(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
+	id := c.Param("id")
+	task := &task_service.TaskPrimaryKey{Id: id}
 
+	if _, ok := h.ownTask(c, id); !ok {
+		return
 	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" || authInfo.UserRole == "user" {
 
-		id := c.Param("id")
-		task := &task_service.TaskPrimaryKey{Id: id}
-
-		resp, err := h.grpcClient.TaskService().Delete(c.Request.Context(), task)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while deleting task")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins, task can change task")
+	resp, err := h.grpcClient.TaskService().Delete(c.Request.Context(), task)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while deleting task")
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -255,30 +214,24 @@ func (h *handler) DeleteTask(c *gin.Context) {
 // @Produce  json
 // @Param		task body  task_service.TaskChangeStatus true "task"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) TaskChangeStatus(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
+	task := &task_service.TaskChangeStatus{}
+	if err := c.ShouldBindJSON(&task); err != nil {
+		abortWithInvalidJSON(c, err)
+		return
 	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" || authInfo.UserRole == "user" {
 
-		task := &task_service.TaskChangeStatus{}
-		if err := c.ShouldBindJSON(&task); err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while reading body")
-			return
-		}
+	if _, ok := h.ownTask(c, task.TaskId); !ok {
+		return
+	}
 
-		resp, err := h.grpcClient.TaskService().ChangeStatus(c.Request.Context(), task)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while changing task's password")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins, task  can change task")
+	resp, err := h.grpcClient.TaskService().ChangeStatus(c.Request.Context(), task)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while changing task's password")
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
@@ -2,9 +2,8 @@ package handler
 
 import (
 	"api_gateway/genproto/admin_service"
+	"api_gateway/pkg/logger"
 	"api_gateway/pkg/validator"
-	"errors"
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -22,45 +21,35 @@ import (
 // @Param		page query int false "page"
 // @Param		limit query int false "limit"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) GetAllAdmin(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
-	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" {
+	admin := &admin_service.GetListAdminRequest{}
 
-		admin := &admin_service.GetListAdminRequest{}
+	search := c.Query("search")
 
-		search := c.Query("search")
-
-		page, err := ParsePageQueryParam(c)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while parsing page")
-			return
-		}
-		limit, err := ParseLimitQueryParam(c)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while parsing limit")
-			return
-		}
+	page, err := ParsePageQueryParam(c)
+	if err != nil {
+		abortWithViolation(c, "invalid page", "page", "must be a non-negative integer")
+		return
+	}
+	limit, err := ParseLimitQueryParam(c)
+	if err != nil {
+		abortWithViolation(c, "invalid limit", "limit", "must be a non-negative integer")
+		return
+	}
 
-		admin.Search = search
-		admin.Offset = int64(page)
-		admin.Limit = int64(limit)
+	admin.Search = search
+	admin.Offset = int64(page)
+	admin.Limit = int64(limit)
 
-		resp, err := h.grpcClient.AdminService().GetList(c.Request.Context(), admin)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while creating admin")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins can change admin")
+	resp, err := h.grpcClient.AdminService().GetList(c.Request.Context(), admin)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while creating admin")
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -73,47 +62,37 @@ func (h *handler) GetAllAdmin(c *gin.Context) {
 // @Produce  json
 // @Param		admin body  admin_service.CreateAdmin true "admin"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) CreateAdmin(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
+	admin := &admin_service.CreateAdmin{}
+	if err := c.ShouldBindJSON(&admin); err != nil {
+		abortWithInvalidJSON(c, err)
+		return
+	}
+
+	if !validator.ValidateGmail(admin.Email) {
+		abortWithViolation(c, "wrong gmail", "email", "must be a gmail.com address")
+		return
+	}
+
+	if !validator.ValidatePhone(admin.Phone) {
+		abortWithViolation(c, "wrong phone", "phone", "must be +998 followed by 9 digits")
+		return
+	}
+
+	err := validator.ValidatePassword(admin.UserPassword)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
-	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" {
-
-		admin := &admin_service.CreateAdmin{}
-		if err := c.ShouldBindJSON(&admin); err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while reading body")
-			return
-		}
-
-		if !validator.ValidateGmail(admin.Email) {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong gmail"), "error while validating gmail")
-			return
-		}
-
-		if !validator.ValidatePhone(admin.Phone) {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong phone"), "error while validating phone")
-			return
-		}
-
-		err := validator.ValidatePassword(admin.UserPassword)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong password"), "error while validating password")
-			return
-		}
-		resp, err := h.grpcClient.AdminService().Create(c.Request.Context(), admin)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while creating admin")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins can change admin")
+		abortWithViolation(c, "wrong password", "user_password", err.Error())
+		return
 	}
+	resp, err := h.grpcClient.AdminService().Create(c.Request.Context(), admin)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while creating admin")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -126,41 +105,34 @@ func (h *handler) CreateAdmin(c *gin.Context) {
 // @Produce  json
 // @Param		admin body  admin_service.UpdateAdmin true "admin"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) UpdateAdmin(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
+	admin := &admin_service.UpdateAdmin{}
+	if err := c.ShouldBindJSON(&admin); err != nil {
+		abortWithInvalidJSON(c, err)
+		return
+	}
+	if !h.checkSelf(c, admin.Id, actionManageAnyAdmin) {
+		return
+	}
+	if !validator.ValidateGmail(admin.Email) {
+		abortWithViolation(c, "wrong gmail", "email", "must be a gmail.com address")
+		return
+	}
+
+	if !validator.ValidatePhone(admin.Phone) {
+		abortWithViolation(c, "wrong phone", "phone", "must be +998 followed by 9 digits")
+		return
+	}
+
+	resp, err := h.grpcClient.AdminService().Update(c.Request.Context(), admin)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
-	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" {
-
-		admin := &admin_service.UpdateAdmin{}
-		if err := c.ShouldBindJSON(&admin); err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while reading body")
-			return
-		}
-		if !validator.ValidateGmail(admin.Email) {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong gmail"), "error while validating gmail")
-			return
-		}
-
-		if !validator.ValidatePhone(admin.Phone) {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong phone"), "error while validating phone")
-			return
-		}
-
-		resp, err := h.grpcClient.AdminService().Update(c.Request.Context(), admin)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while updating admin")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins and admin can change admin")
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while updating admin")
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -173,29 +145,23 @@ func (h *handler) UpdateAdmin(c *gin.Context) {
 // @Produce  json
 // @Param 		id path string true "id"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) GetAdminById(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
+	id := c.Param("id")
+	admin := &admin_service.AdminPrimaryKey{Id: id}
 
+	if !h.checkSelf(c, id, actionManageAnyAdmin) {
+		return
 	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" {
-
-		id := c.Param("id")
-		admin := &admin_service.AdminPrimaryKey{Id: id}
 
-		resp, err := h.grpcClient.AdminService().GetByID(c.Request.Context(), admin)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while getting admin")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins and admins can change admin")
+	resp, err := h.grpcClient.AdminService().GetByID(c.Request.Context(), admin)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while getting admin")
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -208,29 +174,19 @@ func (h *handler) GetAdminById(c *gin.Context) {
 // @Produce  json
 // @Param 		id path string true "id"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) DeleteAdmin(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
+	id := c.Param("id")
+	admin := &admin_service.AdminPrimaryKey{Id: id}
 
+	resp, err := h.grpcClient.AdminService().Delete(c.Request.Context(), admin)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while deleting admin")
+		return
 	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" {
-
-		id := c.Param("id")
-		admin := &admin_service.AdminPrimaryKey{Id: id}
-
-		resp, err := h.grpcClient.AdminService().Delete(c.Request.Context(), admin)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while deleting admin")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins, admin can change admin")
-	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // AdminLogin godoc
@@ -242,108 +198,132 @@ func (h *handler) DeleteAdmin(c *gin.Context) {
 // @Produce      json
 // @Param        login body admin_service.AdminLoginRequest true "login"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		423  {object}  models.LockoutError
+// @Failure		429  {object}  models.LockoutError
+// @Failure		500  {object}  models.Problem
 func (h *handler) AdminLogin(c *gin.Context) {
 	loginReq := &admin_service.AdminLoginRequest{}
 
 	if err := c.ShouldBindJSON(&loginReq); err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "error while binding body")
+		abortWithInvalidJSON(c, err)
 		return
 	}
-	fmt.Println("loginReq: ", loginReq)
+	h.logFor(c).Debug("admin login request", logger.Proto("request", loginReq))
 
 	//TODO: need validate login & password
 
+	attempt, ok := h.reserveLoginAttempt(c, loginAccountAdmin, loginReq.UserLogin)
+	if !ok {
+		return
+	}
+
 	loginResp, err := h.grpcClient.AdminService().Login(c.Request.Context(), loginReq)
+	h.settleLoginAttempt(c, attempt, err)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "unauthorized")
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "unauthorized")
+		return
+	}
+
+	if !h.reissueTokens(c, &loginResp.AccessToken, &loginResp.RefreshToken) {
 		return
 	}
 
-	handleGrpcErrWithDescription(c, h.log, nil, "Succes")
 	c.JSON(http.StatusOK, loginResp)
 
 }
 
 // AdminRegister godoc
+// @Security     ApiKeyAuth
 // @Router       /v1/admin/register [POST]
 // @Summary      Admin register
-// @Description  Admin register
+// @Description  Sends the OTP of an admin registration, superadmin only
 // @Tags         admin
 // @Accept       json
 // @Produce      json
 // @Param        register body admin_service.AdminRegisterRequest true "register"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		401  {object}  models.Problem
+// @Failure		403  {object}  models.Problem
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) AdminRegister(c *gin.Context) {
 	loginReq := &admin_service.AdminRegisterRequest{}
 
 	if err := c.ShouldBindJSON(&loginReq); err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "error while binding body")
+		abortWithInvalidJSON(c, err)
 		return
 	}
-	fmt.Println("loginReq: ", loginReq)
+	h.logFor(c).Debug("admin register request", logger.Proto("request", loginReq))
 
 	//TODO: need validate for (gmail.com or mail.ru) & check if email is not exists
 
 	resp, err := h.grpcClient.AdminService().Register(c.Request.Context(), loginReq)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "error while registr admin")
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while registr admin")
 		return
 	}
 
-	handleGrpcErrWithDescription(c, h.log, nil, "Otp sent successfull")
 	c.JSON(http.StatusOK, resp)
 }
 
 // AdminRegister godoc
+// @Security     ApiKeyAuth
 // @Router       /v1/admin/register-confirm [POST]
 // @Summary      Admin register
-// @Description  Admin register
+// @Description  Creates the invited admin with the OTP of the registration, superadmin only
 // @Tags         admin
 // @Accept       json
 // @Produce      json
 // @Param        register body admin_service.AdminRegisterConfRequest true "register"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		401  {object}  models.Problem
+// @Failure		403  {object}  models.Problem
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) AdminRegisterConfirm(c *gin.Context) {
 	req := &admin_service.AdminRegisterConfRequest{}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "error while binding body")
+		abortWithInvalidJSON(c, err)
+		return
+	}
+	h.logFor(c).Debug("admin register confirm request", logger.Proto("request", req))
+
+	if len(req.Admin) == 0 || req.Admin[0] == nil {
+		abortWithViolation(c, "no admin to register", "Admin", "must contain the admin to register")
 		return
 	}
-	fmt.Println("req: ", req)
 
 	if !validator.ValidateGmail(req.Admin[0].Email) {
-		handleGrpcErrWithDescription(c, h.log, errors.New("wrong gmail"), "error while validating gmail")
+		abortWithViolation(c, "wrong gmail", "Admin[0].email", "must be a gmail.com address")
 		return
 	}
 
 	if !validator.ValidatePhone(req.Admin[0].Phone) {
-		handleGrpcErrWithDescription(c, h.log, errors.New("wrong phone"), "error while validating phone")
+		abortWithViolation(c, "wrong phone", "Admin[0].phone", "must be +998 followed by 9 digits")
 		return
 	}
 
 	err := validator.ValidatePassword(req.Admin[0].UserPassword)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, errors.New("wrong password"), "error while validating password")
+		abortWithViolation(c, "wrong password", "Admin[0].user_password", err.Error())
 		return
 	}
 
 	confResp, err := h.grpcClient.AdminService().RegisterConfirm(c.Request.Context(), req)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "error while confirming")
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while confirming")
+		return
+	}
+
+	if !h.reissueTokens(c, &confResp.AccessToken, &confResp.RefreshToken) {
 		return
 	}
 
-	handleGrpcErrWithDescription(c, h.log, nil, "Succes")
 	c.JSON(http.StatusOK, confResp)
 }
 
@@ -357,35 +337,28 @@ func (h *handler) AdminRegisterConfirm(c *gin.Context) {
 // @Produce  json
 // @Param		admin body  admin_service.AdminChangePassword true "admin"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) AdminChangePassword(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
+	admin := &admin_service.AdminChangePassword{}
+	if err := c.ShouldBindJSON(&admin); err != nil {
+		abortWithInvalidJSON(c, err)
+		return
+	}
+	if !h.checkAdminLogin(c, admin.UserLogin) {
+		return
+	}
+
+	err := validator.ValidatePassword(admin.NewPassword)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
-	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" {
-
-		admin := &admin_service.AdminChangePassword{}
-		if err := c.ShouldBindJSON(&admin); err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while reading body")
-			return
-		}
-
-		err := validator.ValidatePassword(admin.NewPassword)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong password"), "error while validating password")
-			return
-		}
-		resp, err := h.grpcClient.AdminService().ChangePassword(c.Request.Context(), admin)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while changing admin's password")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins, admin  can change admin")
+		abortWithViolation(c, "wrong password", "NewPassword", err.Error())
+		return
 	}
+	resp, err := h.grpcClient.AdminService().ChangePassword(c.Request.Context(), admin)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while changing admin's password")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
 }
@@ -2,9 +2,8 @@ package handler
 
 import (
 	"api_gateway/genproto/user_service"
+	"api_gateway/pkg/logger"
 	"api_gateway/pkg/validator"
-	"errors"
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -22,45 +21,35 @@ import (
 // @Param		page query int false "page"
 // @Param		limit query int false "limit"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) GetAllUser(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
-	}
-	if authInfo.UserRole == "admin" {
+	user := &user_service.GetListUserRequest{}
 
-		user := &user_service.GetListUserRequest{}
+	search := c.Query("search")
 
-		search := c.Query("search")
-
-		page, err := ParsePageQueryParam(c)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while parsing page")
-			return
-		}
-		limit, err := ParseLimitQueryParam(c)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while parsing limit")
-			return
-		}
+	page, err := ParsePageQueryParam(c)
+	if err != nil {
+		abortWithViolation(c, "invalid page", "page", "must be a non-negative integer")
+		return
+	}
+	limit, err := ParseLimitQueryParam(c)
+	if err != nil {
+		abortWithViolation(c, "invalid limit", "limit", "must be a non-negative integer")
+		return
+	}
 
-		user.Search = search
-		user.Offset = int64(page)
-		user.Limit = int64(limit)
+	user.Search = search
+	user.Offset = int64(page)
+	user.Limit = int64(limit)
 
-		resp, err := h.grpcClient.UserService().GetList(c.Request.Context(), user)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while creating user")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins and admins can change user")
+	resp, err := h.grpcClient.UserService().GetList(c.Request.Context(), user)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while creating user")
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -73,53 +62,43 @@ func (h *handler) GetAllUser(c *gin.Context) {
 // @Produce  json
 // @Param		user body  user_service.CreateUser true "user"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) CreateUser(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
+	user := &user_service.CreateUser{}
+	if err := c.ShouldBindJSON(&user); err != nil {
+		abortWithInvalidJSON(c, err)
+		return
+	}
+	if !validator.ValidateGmail(user.Email) {
+		abortWithViolation(c, "wrong gmail", "email", "must be a gmail.com address")
+		return
+	}
+
+	if !validator.ValidatePhone(user.Phone) {
+		abortWithViolation(c, "wrong phone", "phone", "must be +998 followed by 9 digits")
+		return
+	}
+
+	err := validator.ValidateBitrthday(user.Birthday)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
-	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" || authInfo.UserRole == "user" {
-
-		user := &user_service.CreateUser{}
-		if err := c.ShouldBindJSON(&user); err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while reading body")
-			return
-		}
-		if !validator.ValidateGmail(user.Email) {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong gmail"), "error while validating gmail")
-			return
-		}
-
-		if !validator.ValidatePhone(user.Phone) {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong phone"), "error while validating phone")
-			return
-		}
-
-		err := validator.ValidateBitrthday(user.Birthday)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong birthday"), "error while validating birthday")
-			return
-		}
-
-		err = validator.ValidatePassword(user.UserPassword)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong password"), "error while validating password")
-			return
-		}
-
-		resp, err := h.grpcClient.UserService().Create(c.Request.Context(), user)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while creating user")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins and admins can change user")
+		abortWithViolation(c, "wrong birthday", "birthday", err.Error())
+		return
+	}
+
+	err = validator.ValidatePassword(user.UserPassword)
+	if err != nil {
+		abortWithViolation(c, "wrong password", "user_password", err.Error())
+		return
 	}
+
+	resp, err := h.grpcClient.UserService().Create(c.Request.Context(), user)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while creating user")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -132,46 +111,39 @@ func (h *handler) CreateUser(c *gin.Context) {
 // @Produce  json
 // @Param		user body  user_service.UpdateUser true "user"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) UpdateUser(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
+	user := &user_service.UpdateUser{}
+	if err := c.ShouldBindJSON(&user); err != nil {
+		abortWithInvalidJSON(c, err)
+		return
+	}
+	if !h.checkSelf(c, user.Id, actionManageAnyUser) {
+		return
+	}
+	if !validator.ValidateGmail(user.Email) {
+		abortWithViolation(c, "wrong gmail", "email", "must be a gmail.com address")
+		return
+	}
+
+	if !validator.ValidatePhone(user.Phone) {
+		abortWithViolation(c, "wrong phone", "phone", "must be +998 followed by 9 digits")
+		return
+	}
+
+	err := validator.ValidateBitrthday(user.Birthday)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
-	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" || authInfo.UserRole == "user" {
-
-		user := &user_service.UpdateUser{}
-		if err := c.ShouldBindJSON(&user); err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while reading body")
-			return
-		}
-		if !validator.ValidateGmail(user.Email) {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong gmail"), "error while validating gmail")
-			return
-		}
-
-		if !validator.ValidatePhone(user.Phone) {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong phone"), "error while validating phone")
-			return
-		}
-
-		err := validator.ValidateBitrthday(user.Birthday)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong gmail"), "error while validating gmail")
-			return
-		}
-		resp, err := h.grpcClient.UserService().Update(c.Request.Context(), user)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while updating user")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins, user and admins can change user")
+		abortWithViolation(c, "wrong birthday", "birthday", err.Error())
+		return
 	}
+	resp, err := h.grpcClient.UserService().Update(c.Request.Context(), user)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while updating user")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -184,29 +156,23 @@ func (h *handler) UpdateUser(c *gin.Context) {
 // @Produce  json
 // @Param 		id path string true "id"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) GetUserById(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
+	id := c.Param("id")
+	user := &user_service.UserPrimaryKey{Id: id}
 
+	if !h.checkSelf(c, id, actionManageAnyUser) {
+		return
 	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" || authInfo.UserRole == "user" {
-
-		id := c.Param("id")
-		user := &user_service.UserPrimaryKey{Id: id}
 
-		resp, err := h.grpcClient.UserService().GetByID(c.Request.Context(), user)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while getting user")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins, user and admins can change user")
+	resp, err := h.grpcClient.UserService().GetByID(c.Request.Context(), user)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while getting user")
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // @Security ApiKeyAuth
@@ -219,29 +185,19 @@ func (h *handler) GetUserById(c *gin.Context) {
 // @Produce  json
 // @Param 		id path string true "id"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) DeleteUser(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
-	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
-	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" || authInfo.UserRole == "user" {
-
-		id := c.Param("id")
-		user := &user_service.UserPrimaryKey{Id: id}
+	id := c.Param("id")
+	user := &user_service.UserPrimaryKey{Id: id}
 
-		resp, err := h.grpcClient.UserService().Delete(c.Request.Context(), user)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while deleting user")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins, user and admins can change user")
+	resp, err := h.grpcClient.UserService().Delete(c.Request.Context(), user)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while deleting user")
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // UserLogin godoc
@@ -253,27 +209,38 @@ func (h *handler) DeleteUser(c *gin.Context) {
 // @Produce      json
 // @Param        login body user_service.UserLoginRequest true "login"
 // @Success		 200  {object}  models.ResponseSuccess
-// @Failure		 400  {object}  models.ResponseError
-// @Failure		 404  {object}  models.ResponseError
-// @Failure		 500  {object}  models.ResponseError
+// @Failure		 400  {object}  models.Problem
+// @Failure		 404  {object}  models.Problem
+// @Failure		 423  {object}  models.LockoutError
+// @Failure		 429  {object}  models.LockoutError
+// @Failure		 500  {object}  models.Problem
 func (h *handler) UserLogin(c *gin.Context) {
 	loginReq := &user_service.UserLoginRequest{}
 
 	if err := c.ShouldBindJSON(&loginReq); err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "error while binding body")
+		abortWithInvalidJSON(c, err)
 		return
 	}
-	fmt.Println("loginReq: ", loginReq)
+	h.logFor(c).Debug("user login request", logger.Proto("request", loginReq))
 
 	//TODO: need validate login & password
 
+	attempt, ok := h.reserveLoginAttempt(c, loginAccountUser, loginReq.UserLogin)
+	if !ok {
+		return
+	}
+
 	loginResp, err := h.grpcClient.UserService().Login(c.Request.Context(), loginReq)
+	h.settleLoginAttempt(c, attempt, err)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "unauthorized")
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "unauthorized")
+		return
+	}
+
+	if !h.reissueTokens(c, &loginResp.AccessToken, &loginResp.RefreshToken) {
 		return
 	}
 
-	handleGrpcErrWithDescription(c, h.log, nil, "Succes")
 	c.JSON(http.StatusOK, loginResp)
 
 }
@@ -287,25 +254,24 @@ func (h *handler) UserLogin(c *gin.Context) {
 // @Produce      json
 // @Param        register body user_service.UserRegisterRequest true "register"
 // @Success	   	 200  {object}  models.ResponseSuccess
-// @Failure		 400  {object}  models.ResponseError
-// @Failure	     404  {object}  models.ResponseError
-// @Failure		 500  {object}  models.ResponseError
+// @Failure		 400  {object}  models.Problem
+// @Failure	     404  {object}  models.Problem
+// @Failure		 500  {object}  models.Problem
 func (h *handler) UserRegister(c *gin.Context) {
 	loginReq := &user_service.UserRegisterRequest{}
 
 	if err := c.ShouldBindJSON(&loginReq); err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "error while binding body")
+		abortWithInvalidJSON(c, err)
 		return
 	}
-	fmt.Println("loginReq: ", loginReq)
+	h.logFor(c).Debug("user register request", logger.Proto("request", loginReq))
 
 	resp, err := h.grpcClient.UserService().Register(c.Request.Context(), loginReq)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "error while registr user")
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while registr user")
 		return
 	}
 
-	handleGrpcErrWithDescription(c, h.log, nil, "Otp sent successfull")
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -318,40 +284,48 @@ func (h *handler) UserRegister(c *gin.Context) {
 // @Produce      json
 // @Param        register body user_service.UserRegisterConfRequest true "register"
 // @Success		 200  {object}  models.ResponseSuccess
-// @Failure		 400  {object}  models.ResponseError
-// @Failure		 404  {object}  models.ResponseError
-// @Failure		 500  {object}  models.ResponseError
+// @Failure		 400  {object}  models.Problem
+// @Failure		 404  {object}  models.Problem
+// @Failure		 500  {object}  models.Problem
 func (h *handler) UserRegisterConfirm(c *gin.Context) {
 	req := &user_service.UserRegisterConfRequest{}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "error while binding body")
+		abortWithInvalidJSON(c, err)
+		return
+	}
+	h.logFor(c).Debug("user register confirm request", logger.Proto("request", req))
+
+	if len(req.User) == 0 || req.User[0] == nil {
+		abortWithViolation(c, "no user to register", "User", "must contain the user to register")
 		return
 	}
-	fmt.Println("req: ", req)
 
 	if !validator.ValidateGmail(req.User[0].Email) {
-		handleGrpcErrWithDescription(c, h.log, errors.New("wrong gmail"), "error while validating gmail")
+		abortWithViolation(c, "wrong gmail", "User[0].email", "must be a gmail.com address")
 		return
 	}
 
 	if !validator.ValidatePhone(req.User[0].Phone) {
-		handleGrpcErrWithDescription(c, h.log, errors.New("wrong phone"), "error while validating phone")
+		abortWithViolation(c, "wrong phone", "User[0].phone", "must be +998 followed by 9 digits")
 		return
 	}
 
 	err := validator.ValidatePassword(req.User[0].UserPassword)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, errors.New("wrong password"), "error while validating password")
+		abortWithViolation(c, "wrong password", "User[0].user_password", err.Error())
 		return
 	}
 	confResp, err := h.grpcClient.UserService().RegisterConfirm(c.Request.Context(), req)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "error while confirming")
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while confirming")
+		return
+	}
+
+	if !h.reissueTokens(c, &confResp.AccessToken, &confResp.RefreshToken) {
 		return
 	}
 
-	handleGrpcErrWithDescription(c, h.log, nil, "Succes")
 	c.JSON(http.StatusOK, confResp)
 }
 
@@ -365,35 +339,28 @@ func (h *handler) UserRegisterConfirm(c *gin.Context) {
 // @Produce  json
 // @Param		user body  user_service.UserChangePassword true "user"
 // @Success		200  {object}  models.ResponseSuccess
-// @Failure		400  {object}  models.ResponseError
-// @Failure		404  {object}  models.ResponseError
-// @Failure		500  {object}  models.ResponseError
+// @Failure		400  {object}  models.Problem
+// @Failure		404  {object}  models.Problem
+// @Failure		500  {object}  models.Problem
 func (h *handler) UserChangePassword(c *gin.Context) {
-	authInfo, err := getAuthInfo(c)
+	user := &user_service.UserChangePassword{}
+	if err := c.ShouldBindJSON(&user); err != nil {
+		abortWithInvalidJSON(c, err)
+		return
+	}
+	if !h.checkUserLogin(c, user.UserLogin) {
+		return
+	}
+
+	err := validator.ValidatePassword(user.NewPassword)
 	if err != nil {
-		handleGrpcErrWithDescription(c, h.log, err, "Unauthorized")
-
-	}
-	if authInfo.UserRole == "superadmin" || authInfo.UserRole == "admin" || authInfo.UserRole == "user" {
-
-		user := &user_service.UserChangePassword{}
-		if err := c.ShouldBindJSON(&user); err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while reading body")
-			return
-		}
-
-		err := validator.ValidatePassword(user.NewPassword)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, errors.New("wrong password"), "error while validating password")
-			return
-		}
-		resp, err := h.grpcClient.UserService().ChangePassword(c.Request.Context(), user)
-		if err != nil {
-			handleGrpcErrWithDescription(c, h.log, err, "error while changing user's password")
-			return
-		}
-		c.JSON(http.StatusOK, resp)
-	} else {
-		handleGrpcErrWithDescription(c, h.log, errors.New("Forbidden"), "Only superadmins, managers, user and admins can change user")
+		abortWithViolation(c, "wrong password", "NewPassword", err.Error())
+		return
 	}
+	resp, err := h.grpcClient.UserService().ChangePassword(c.Request.Context(), user)
+	if err != nil {
+		handleGrpcErrWithDescription(c, h.logFor(c), err, "error while changing user's password")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
 }
@@ -0,0 +1,351 @@
+package api_test
+
+import (
+	"api_gateway/api"
+	"api_gateway/api/models"
+	"api_gateway/config"
+	"api_gateway/genproto/task_service"
+	"api_gateway/pkg/devbackend"
+	"api_gateway/pkg/grpc_client"
+	"api_gateway/pkg/jwt"
+	"api_gateway/pkg/lockout"
+	"api_gateway/pkg/logger"
+	"api_gateway/pkg/ratelimit"
+	"api_gateway/pkg/revocation"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testSigningKey is the HS256 secret the gateway shares with the services in the tests.
+const testSigningKey = "api-test-signing-key-0123456789abcdef"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	jwt.SetSigningKey([]byte(testSigningKey))
+	os.Exit(m.Run())
+}
+
+var testLog = logger.New(logger.LevelError, "api_test")
+
+// testServer is the gin engine of api.New talking over bufconn to gRPC services
+// served in the test process.
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+}
+
+// serverOption adjusts the api.Config of a test server.
+type serverOption func(cnf *api.Config)
+
+// withRateLimits limits the public and the secured routes.
+func withRateLimits(auth, secured string) serverOption {
+	return func(cnf *api.Config) {
+		cnf.Limiter = ratelimit.NewInMemory()
+		cnf.Cfg.RateLimitAuth = auth
+		cnf.Cfg.RateLimitAPI = secured
+	}
+}
+
+// withConfig changes the gateway configuration.
+func withConfig(apply func(cfg *config.Config)) serverOption {
+	return func(cnf *api.Config) {
+		apply(&cnf.Cfg)
+	}
+}
+
+func testConfig() config.Config {
+	return config.Config{
+		Environment: "test",
+
+		LoginMaxFailures:     3,
+		LoginIPMaxFailures:   100,
+		LoginLockoutDuration: time.Minute,
+		LoginFailureWindow:   time.Minute,
+
+		BreakerFailureThreshold: 5,
+		BreakerOpenTimeout:      time.Minute,
+		BreakerHalfOpenProbes:   1,
+
+		GrpcRetryMaxAttempts:    2,
+		GrpcRetryInitialBackoff: time.Millisecond,
+		GrpcRetryMaxBackoff:     time.Millisecond,
+		GrpcRetryBudget:         3,
+		GrpcTimeout:             5 * time.Second,
+	}
+}
+
+// newTestServer builds the gateway in front of the in-memory dev backends.
+func newTestServer(t *testing.T, opts ...serverOption) *testServer {
+	t.Helper()
+
+	backends, err := devbackend.Start(testLog)
+	if err != nil {
+		t.Fatalf("start dev backends: %v", err)
+	}
+	t.Cleanup(backends.Stop)
+
+	cfg := testConfig()
+	backends.Configure(&cfg)
+	return buildServer(t, cfg, backends.DialOptions(), opts...)
+}
+
+// newStubServer builds the gateway in front of the services registered by register,
+// for responses the dev backends never give.
+func newStubServer(t *testing.T, register func(s *grpc.Server), opts ...serverOption) *testServer {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	cfg := testConfig()
+	cfg.UserServiceAddrs = []string{"stub-user-service:0"}
+	cfg.TaskServiceAddrs = []string{"stub-task-service:0"}
+	cfg.UserServiceLB = config.LBConfig{Policy: config.LBPolicyPickFirst}
+	cfg.TaskServiceLB = config.LBConfig{Policy: config.LBPolicyPickFirst}
+	cfg.UserServiceTLS = config.TLSConfig{Mode: config.TLSModePlaintext}
+	cfg.TaskServiceTLS = config.TLSConfig{Mode: config.TLSModePlaintext}
+
+	dialer := grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	})
+	return buildServer(t, cfg, []grpc.DialOption{dialer}, opts...)
+}
+
+// stubTasks answers every task creation and task list with err after delay.
+// It counts the calls and keeps the metadata of the last one.
+type stubTasks struct {
+	task_service.UnimplementedTaskServiceServer
+	err   error
+	delay time.Duration
+	calls atomic.Int32
+
+	mu sync.Mutex
+	md metadata.MD
+}
+
+func (st *stubTasks) Create(ctx context.Context, _ *task_service.CreateTask) (*task_service.GetTask, error) {
+	return nil, st.answer(ctx)
+}
+
+func (st *stubTasks) GetList(ctx context.Context, _ *task_service.GetListTaskRequest) (*task_service.GetListTaskResponse, error) {
+	return nil, st.answer(ctx)
+}
+
+func (st *stubTasks) answer(ctx context.Context) error {
+	st.calls.Add(1)
+	md, _ := metadata.FromIncomingContext(ctx)
+	st.mu.Lock()
+	st.md = md
+	st.mu.Unlock()
+
+	select {
+	case <-time.After(st.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return st.err
+}
+
+// metadata returns the values of key in the metadata of the last call.
+func (st *stubTasks) metadata(key string) []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.md.Get(key)
+}
+
+// newTaskStubServer builds the gateway in front of tasks, the user service is unimplemented.
+func newTaskStubServer(t *testing.T, tasks *stubTasks, opts ...serverOption) *testServer {
+	return newStubServer(t, func(s *grpc.Server) {
+		task_service.RegisterTaskServiceServer(s, tasks)
+	}, opts...)
+}
+
+func buildServer(t *testing.T, cfg config.Config, dialOpts []grpc.DialOption, opts ...serverOption) *testServer {
+	t.Helper()
+
+	cnf := api.Config{
+		Logger:     testLog,
+		Cfg:        cfg,
+		TokenStore: revocation.NewInMemory(),
+		Lockouts:   lockout.NewInMemory(),
+	}
+	for _, opt := range opts {
+		opt(&cnf)
+	}
+
+	client, err := grpc_client.New(cnf.Cfg, testLog, dialOpts...)
+	if err != nil {
+		t.Fatalf("grpc client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	cnf.GrpcClient = client
+
+	return &testServer{t: t, router: api.New(cnf)}
+}
+
+// do serves a request, body is encoded as JSON unless it is a string.
+func (s *testServer) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	return s.doWithHeaders(method, path, token, nil, body)
+}
+
+// doWithHeaders serves a request with extra headers, see do.
+func (s *testServer) doWithHeaders(method, path, token string, headers map[string]string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatalf("encode body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect checks the status of a response and decodes its body into v unless v is nil.
+func expect(t *testing.T, w *httptest.ResponseRecorder, code int, v interface{}) {
+	t.Helper()
+
+	if w.Code != code {
+		t.Fatalf("status = %d, want %d, body: %s", w.Code, code, w.Body.String())
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("decode %s: %v", w.Body.String(), err)
+		}
+	}
+}
+
+// expectError checks that w is the problem details of status with detail and returns it.
+func expectError(t *testing.T, w *httptest.ResponseRecorder, status int, detail string) models.Problem {
+	t.Helper()
+
+	var problem models.Problem
+	expect(t, w, status, &problem)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+json") {
+		t.Fatalf("Content-Type = %q, want application/problem+json", ct)
+	}
+	if problem.Status != status || problem.Code == "" || problem.Title == "" {
+		t.Fatalf("problem = %+v, want status %d with a code and title", problem, status)
+	}
+	if problem.Detail != detail {
+		t.Fatalf("detail = %q, want %q", problem.Detail, detail)
+	}
+	return problem
+}
+
+var mailSeq atomic.Int64
+
+// newMail returns an address accepted by the gateway validators and unused in the test process.
+func newMail() string {
+	return fmt.Sprintf("e2e.user%d@gmail.com", mailSeq.Add(1))
+}
+
+// loginSuperadmin returns the tokens of the superadmin seeded by the dev backends.
+func (s *testServer) loginSuperadmin() models.TokenResponse {
+	s.t.Helper()
+
+	var tokens models.TokenResponse
+	expect(s.t, s.do(http.MethodPost, "/v1/admin/login", "", map[string]string{
+		"user_login":    devbackend.SuperadminLogin,
+		"user_password": devbackend.SuperadminPassword,
+	}), http.StatusOK, &tokens)
+	return tokens
+}
+
+// account is a user or an admin created by a test.
+type account struct {
+	ID       string
+	Login    string
+	Password string
+	Tokens   models.TokenResponse
+}
+
+// registerUser signs up a new user through the OTP flow.
+func (s *testServer) registerUser() account {
+	s.t.Helper()
+
+	a := account{Login: newMail(), Password: "Passw0rd!"}
+	expect(s.t, s.do(http.MethodPost, "/v1/user/register", "", map[string]string{"mail": a.Login}), http.StatusOK, nil)
+	expect(s.t, s.do(http.MethodPost, "/v1/user/register-confirm", "", map[string]interface{}{
+		"mail": a.Login,
+		"otp":  devbackend.OTP,
+		"User": []map[string]string{{
+			"email":         a.Login,
+			"phone":         "+998901234567",
+			"fullname":      "E2E User",
+			"user_password": a.Password,
+		}},
+	}), http.StatusOK, &a.Tokens)
+
+	a.ID = s.me(a.Tokens.AccessToken).UserID
+	return a
+}
+
+// createAdmin creates an admin as superadmin and signs it in.
+func (s *testServer) createAdmin() account {
+	s.t.Helper()
+
+	a := account{Login: newMail(), Password: "Passw0rd!"}
+	expect(s.t, s.do(http.MethodPost, "/v1/admin/create", s.loginSuperadmin().AccessToken, map[string]string{
+		"email":         a.Login,
+		"phone":         "+998901234567",
+		"fullname":      "E2E Admin",
+		"user_password": a.Password,
+	}), http.StatusOK, nil)
+
+	expect(s.t, s.do(http.MethodPost, "/v1/admin/login", "", map[string]string{
+		"user_login":    a.Login,
+		"user_password": a.Password,
+	}), http.StatusOK, &a.Tokens)
+
+	a.ID = s.me(a.Tokens.AccessToken).UserID
+	return a
+}
+
+func (s *testServer) me(token string) models.AuthInfo {
+	s.t.Helper()
+
+	var me models.AuthInfo
+	expect(s.t, s.do(http.MethodGet, "/v1/me", token, nil), http.StatusOK, &me)
+	return me
+}
@@ -0,0 +1,136 @@
+package api_test
+
+import (
+	"api_gateway/api"
+	"api_gateway/pkg/devbackend"
+	"api_gateway/pkg/logger"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// withObservedLogs records the log lines of the gateway at every level.
+func withObservedLogs(logs **observer.ObservedLogs) serverOption {
+	return func(cnf *api.Config) {
+		core, observed := observer.New(zapcore.DebugLevel)
+		cnf.Logger = logger.FromZap(zap.New(core))
+		*logs = observed
+	}
+}
+
+// accessLogOf returns the fields of the access log line of path.
+func accessLogOf(t *testing.T, logs *observer.ObservedLogs, path string) map[string]interface{} {
+	t.Helper()
+
+	for _, entry := range logs.FilterMessage("request").All() {
+		if fields := entry.ContextMap(); fields["path"] == path {
+			return fields
+		}
+	}
+	t.Fatalf("no access log line for %s", path)
+	return nil
+}
+
+// loggedRequest decodes the proto message logged as the request of entry.
+func loggedRequest(t *testing.T, entry observer.LoggedEntry) map[string]interface{} {
+	t.Helper()
+
+	data, err := json.Marshal(entry.ContextMap()["request"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var request map[string]interface{}
+	if err := json.Unmarshal(data, &request); err != nil {
+		t.Fatalf("%q: request is not a JSON object: %s", entry.Message, data)
+	}
+	return request
+}
+
+func TestAccessLog(t *testing.T) {
+	var logs *observer.ObservedLogs
+	s := newTestServer(t, withObservedLogs(&logs))
+	u := s.registerUser()
+
+	w := s.doWithRequestID(http.MethodGet, "/v1/user/get/"+u.ID, u.Tokens.AccessToken, "access-log-test")
+	expect(t, w, http.StatusOK, nil)
+
+	fields := accessLogOf(t, logs, "/v1/user/get/"+u.ID)
+	for key, want := range map[string]interface{}{
+		"method":     http.MethodGet,
+		"route":      "/v1/user/get/:id",
+		"status":     int64(http.StatusOK),
+		"user_id":    u.ID,
+		"request_id": "access-log-test",
+	} {
+		if fields[key] != want {
+			t.Errorf("%s = %v, want %v", key, fields[key], want)
+		}
+	}
+	if _, ok := fields["latency"]; !ok {
+		t.Error("no latency")
+	}
+
+	s.do(http.MethodGet, "/v1/nothing", "", nil)
+	if fields := accessLogOf(t, logs, "/v1/nothing"); fields["route"] != "unmatched" || fields["status"] != int64(http.StatusNotFound) {
+		t.Fatalf("access log = %v", fields)
+	}
+}
+
+func TestAccessLogCarriesTheTrace(t *testing.T) {
+	withTracing(t)
+	var logs *observer.ObservedLogs
+	s := newTestServer(t, withObservedLogs(&logs))
+
+	expect(t, s.doWithTraceparent(http.MethodGet, "/v1/me", tokenFor(t, user), ""), http.StatusOK, nil)
+
+	if fields := accessLogOf(t, logs, "/v1/me"); fields["trace_id"] != callerTraceID || fields["span_id"] == nil {
+		t.Fatalf("access log = %v, want trace %s", fields, callerTraceID)
+	}
+}
+
+func TestLogsRedactSecrets(t *testing.T) {
+	var logs *observer.ObservedLogs
+	s := newTestServer(t, withObservedLogs(&logs))
+	u := s.registerUser()
+
+	expect(t, s.do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"user_login":    u.Login,
+		"user_password": u.Password,
+	}), http.StatusOK, nil)
+	s.loginSuperadmin()
+
+	entries := logs.FilterMessage("user login request").All()
+	if len(entries) != 1 {
+		t.Fatalf("%d user login request lines, want 1", len(entries))
+	}
+	if request := loggedRequest(t, entries[0]); request["user_login"] != u.Login || request["user_password"] != logger.Redacted {
+		t.Fatalf("request = %v", request)
+	}
+
+	confirm := logs.FilterMessage("user register confirm request").All()
+	if len(confirm) != 1 {
+		t.Fatalf("%d user register confirm request lines, want 1", len(confirm))
+	}
+	if otp := loggedRequest(t, confirm[0])["otp"]; otp != logger.Redacted {
+		t.Fatalf("otp = %v, want %s", otp, logger.Redacted)
+	}
+
+	// the OTP is short enough to be part of other values, it is checked above
+	secrets := []string{u.Password, devbackend.SuperadminPassword, u.Tokens.AccessToken}
+	for _, entry := range logs.All() {
+		line, err := json.Marshal(entry.ContextMap())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range secrets {
+			if strings.Contains(string(line), secret) {
+				t.Errorf("%q logs a secret: %s", entry.Message, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package api_test
+
+import (
+	"api_gateway/config"
+	"api_gateway/pkg/devbackend"
+	"net/http"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// metric returns the value of the series of name with labels, scraped from
+// /metrics: the value of counters and gauges, the sample count of histograms,
+// 0 when there is no such series. Metrics are shared by all test servers,
+// tests compare values before and after their requests.
+func (s *testServer) metric(name string, labels map[string]string) float64 {
+	s.t.Helper()
+
+	families := s.scrape()
+	family, ok := families["api_gateway_"+name]
+	if !ok {
+		return 0
+	}
+
+next:
+	for _, m := range family.GetMetric() {
+		for _, pair := range m.GetLabel() {
+			if want, ok := labels[pair.GetName()]; ok && want != pair.GetValue() {
+				continue next
+			}
+		}
+		switch {
+		case m.Counter != nil:
+			return m.GetCounter().GetValue()
+		case m.Gauge != nil:
+			return m.GetGauge().GetValue()
+		case m.Histogram != nil:
+			return float64(m.GetHistogram().GetSampleCount())
+		}
+	}
+	return 0
+}
+
+func (s *testServer) scrape() map[string]*dto.MetricFamily {
+	s.t.Helper()
+
+	w := s.do(http.MethodGet, "/metrics", "", nil)
+	if w.Code != http.StatusOK {
+		s.t.Fatalf("GET /metrics: status = %d, body: %s", w.Code, w.Body.String())
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(w.Body)
+	if err != nil {
+		s.t.Fatalf("parse /metrics: %v", err)
+	}
+	return families
+}
+
+func TestHTTPMetricsUseRouteTemplates(t *testing.T) {
+	s := newTestServer(t)
+	token := tokenFor(t, user)
+
+	route := map[string]string{"method": http.MethodGet, "route": "/v1/task/get/:id", "status": "404"}
+	before := s.metric("http_requests_total", route)
+	beforeLatency := s.metric("http_request_duration_seconds", route)
+
+	for _, id := range []string{"00000000-0000-4000-8000-000000000001", "00000000-0000-4000-8000-000000000002"} {
+		expectError(t, s.do(http.MethodGet, "/v1/task/get/"+id, token, nil), http.StatusNotFound, "task "+id+" not found")
+	}
+
+	if got := s.metric("http_requests_total", route) - before; got != 2 {
+		t.Fatalf("requests of %v = %v, want 2", route, got)
+	}
+	if got := s.metric("http_request_duration_seconds", route) - beforeLatency; got != 2 {
+		t.Fatalf("latency samples of %v = %v, want 2", route, got)
+	}
+	if got := s.metric("http_requests_in_flight", route); got != 0 {
+		t.Fatalf("in flight = %v, want 0 after the requests", got)
+	}
+
+	w := s.do(http.MethodGet, "/metrics", "", nil)
+	if strings.Contains(w.Body.String(), "00000000-0000-4000-8000-000000000001") {
+		t.Fatal("raw paths are used as route labels")
+	}
+
+	unmatched := map[string]string{"route": "unmatched", "status": "404"}
+	before = s.metric("http_requests_total", unmatched)
+	s.do(http.MethodGet, "/v1/nothing/here", "", nil)
+	if got := s.metric("http_requests_total", unmatched) - before; got != 1 {
+		t.Fatalf("unmatched requests = %v, want 1", got)
+	}
+}
+
+func TestHTTPMetricsBoundMethods(t *testing.T) {
+	s := newTestServer(t)
+
+	other := map[string]string{"method": "OTHER", "route": "unmatched"}
+	before := s.metric("http_requests_total", other)
+	for _, method := range []string{"SCAN-1", "SCAN-2"} {
+		s.do(method, "/healthz", "", nil)
+	}
+
+	if got := s.metric("http_requests_total", other) - before; got != 2 {
+		t.Fatalf("requests of %v = %v, want 2", other, got)
+	}
+	if body := s.do(http.MethodGet, "/metrics", "", nil).Body.String(); strings.Contains(body, "SCAN-") {
+		t.Fatal("raw methods are used as method labels")
+	}
+}
+
+func TestGrpcClientMetrics(t *testing.T) {
+	tasks := &stubTasks{err: status.Error(codes.Unavailable, "down")}
+	s := newTaskStubServer(t, tasks)
+
+	call := map[string]string{"service": "task_service", "method": "GetList", "code": "Unavailable"}
+	before := s.metric("grpc_client_requests_total", call)
+
+	expectError(t, s.do(http.MethodGet, "/v1/me/tasks", tokenFor(t, user), nil),
+		http.StatusServiceUnavailable, "")
+
+	// one call, however many attempts
+	if got := s.metric("grpc_client_requests_total", call) - before; got != 1 {
+		t.Fatalf("calls = %v, want 1", got)
+	}
+	if got := s.metric("grpc_client_request_duration_seconds", map[string]string{"service": "task_service", "method": "GetList"}); got == 0 {
+		t.Fatal("no latency samples")
+	}
+
+	s = newTestServer(t)
+	ok := map[string]string{"service": "admin_service", "method": "Login", "code": "OK"}
+	before = s.metric("grpc_client_requests_total", ok)
+	s.loginSuperadmin()
+	if got := s.metric("grpc_client_requests_total", ok) - before; got != 1 {
+		t.Fatalf("calls = %v, want 1", got)
+	}
+}
+
+func TestAuthFailureMetrics(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, tc := range []struct {
+		reason string
+		do     func()
+	}{
+		{"invalid_token", func() { s.do(http.MethodGet, "/v1/me", "", nil) }},
+		{"invalid_token", func() { s.do(http.MethodPost, "/v1/auth/refresh", "", map[string]string{"refresh_token": "not-a-jwt"}) }},
+		{"forbidden", func() { s.do(http.MethodGet, "/v1/admin/getall", tokenFor(t, user), nil) }},
+		{"revoked_token", func() {
+			token := tokenFor(t, user)
+			expect(t, s.do(http.MethodPost, "/v1/auth/logout", token, nil), http.StatusOK, nil)
+			s.do(http.MethodGet, "/v1/me", token, nil)
+		}},
+	} {
+		reason := map[string]string{"reason": tc.reason}
+		before := s.metric("auth_failures_total", reason)
+		tc.do()
+		if got := s.metric("auth_failures_total", reason) - before; got != 1 {
+			t.Fatalf("%s failures = %v, want 1", tc.reason, got)
+		}
+	}
+}
+
+func TestLoginAndRateLimitMetrics(t *testing.T) {
+	// signing up, 5 logins, then the next request is rejected
+	s := newTestServer(t, withRateLimits("7/1m", "off"))
+	u := s.registerUser()
+
+	attempts := func(result string) float64 {
+		return s.metric("login_attempts_total", map[string]string{"account": "user", "result": result})
+	}
+	login := func(password string) map[string]string {
+		return map[string]string{"user_login": u.Login, "user_password": password}
+	}
+
+	success, failure, locked := attempts("success"), attempts("failure"), attempts("locked")
+	rejected := s.metric("rate_limit_rejections_total", map[string]string{"group": "auth"})
+
+	expect(t, s.do(http.MethodPost, "/v1/user/login", "", login(u.Password)), http.StatusOK, nil)
+	// LoginMaxFailures of testConfig
+	for i := 0; i < 3; i++ {
+		s.do(http.MethodPost, "/v1/user/login", "", login("wrong"))
+	}
+	expect(t, s.do(http.MethodPost, "/v1/user/login", "", login(u.Password)), http.StatusLocked, nil)
+	expectError(t, s.do(http.MethodPost, "/v1/admin/login", "", map[string]string{
+		"user_login":    devbackend.SuperadminLogin,
+		"user_password": devbackend.SuperadminPassword,
+	}), http.StatusTooManyRequests, "Too Many Requests")
+
+	for result, want := range map[string]float64{"success": success + 1, "failure": failure + 3, "locked": locked + 1} {
+		if got := attempts(result); got != want {
+			t.Errorf("%s logins = %v, want %v", result, got, want)
+		}
+	}
+	if got := s.metric("rate_limit_rejections_total", map[string]string{"group": "auth"}) - rejected; got != 1 {
+		t.Fatalf("rate limit rejections = %v, want 1", got)
+	}
+}
+
+func TestMetricsOnSeparatePort(t *testing.T) {
+	s := newTestServer(t, withConfig(func(cfg *config.Config) {
+		cfg.MetricsPort = "9090"
+	}))
+
+	expectError(t, s.do(http.MethodGet, "/metrics", "", nil), http.StatusNotFound, "no route for GET /metrics")
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // ResponseSuccess ...
 type ResponseSuccess struct {
 	Metadata interface{}
@@ -32,11 +34,43 @@ type Response struct {
 	ID interface{} `json:"id"`
 }
 
-type ErrorWithDescription struct {
-	Code        int    `json:"code"`
+// Problem is the body of every error response, an RFC 7807 problem
+// details object served as application/problem+json.
+type Problem struct {
+	Type       string      `json:"type"`
+	Title      string      `json:"title"`
+	Status     int         `json:"status"`
+	Detail     string      `json:"detail,omitempty"`
+	Instance   string      `json:"instance,omitempty"`
+	Code       string      `json:"code"` // stable machine-readable code, see handler.ErrorCode*
+	RequestID  string      `json:"request_id,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// Violation is a request field that failed validation.
+type Violation struct {
+	Field       string `json:"field"`
 	Description string `json:"description"`
 }
 
+// LockoutError is the Problem of a login refused after too many failures.
+type LockoutError struct {
+	Problem
+	UnlockAt   time.Time `json:"unlock_at"`
+	RetryAfter int       `json:"retry_after"`
+}
+
+type DependencyStatus struct {
+	Status string `json:"status"`
+	State  string `json:"state,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
 type ErrorReason struct {
 	Reason string `json:"reason"`
 }
@@ -54,4 +88,34 @@ type GetListCategoryRequest struct {
 type AuthInfo struct {
 	UserID   string `json:"user_id"`
 	UserRole string `json:"user_role"`
+
+	TokenID   string    `json:"-"`
+	IssuedAt  time.Time `json:"-"`
+	ExpiresAt time.Time `json:"-"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+type UpdateProfileRequest struct {
+	Birthday string `json:"birthday"`
+	Gender   string `json:"gender"`
+	Fullname string `json:"fullname"`
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
 }
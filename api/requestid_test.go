@@ -0,0 +1,83 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *testServer) doWithRequestID(method, path, token, id string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(`{"title":"task"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestIDIsEchoed(t *testing.T) {
+	s := newTestServer(t)
+
+	w := s.doWithRequestID(http.MethodGet, "/healthz", "", "client-id-1")
+	if got := w.Header().Get("X-Request-ID"); got != "client-id-1" {
+		t.Fatalf("X-Request-ID = %q, want the one of the client", got)
+	}
+
+	p := expectError(t, s.doWithRequestID(http.MethodGet, "/v1/me", "", "client-id-2"),
+		http.StatusUnauthorized, "authorization header is missing")
+	if p.RequestID != "client-id-2" {
+		t.Fatalf("request_id = %q, want client-id-2", p.RequestID)
+	}
+
+	p = expectError(t, s.doWithRequestID(http.MethodGet, "/v1/nothing", "", "client-id-3"),
+		http.StatusNotFound, "no route for GET /v1/nothing")
+	if p.RequestID != "client-id-3" {
+		t.Fatalf("request_id = %q, want client-id-3", p.RequestID)
+	}
+}
+
+func TestRequestIDIsGenerated(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, id := range []string{"", "has spaces", "new\nline", strings.Repeat("x", 129)} {
+		w := s.doWithRequestID(http.MethodGet, "/v1/me", "", id)
+		got := w.Header().Get("X-Request-ID")
+		if got == "" || got == id {
+			t.Fatalf("X-Request-ID = %q for %q, want a generated one", got, id)
+		}
+		if p := expectError(t, w, http.StatusUnauthorized, "authorization header is missing"); p.RequestID != got {
+			t.Fatalf("request_id = %q, want the header %q", p.RequestID, got)
+		}
+	}
+
+	first := s.doWithRequestID(http.MethodGet, "/healthz", "", "").Header().Get("X-Request-ID")
+	second := s.doWithRequestID(http.MethodGet, "/healthz", "", "").Header().Get("X-Request-ID")
+	if first == second {
+		t.Fatalf("request IDs repeat: %q", first)
+	}
+}
+
+func TestRequestIDIsForwardedToServices(t *testing.T) {
+	tasks := &stubTasks{err: status.Error(codes.Internal, "boom")}
+	s := newTaskStubServer(t, tasks)
+
+	w := s.doWithRequestID(http.MethodPost, "/v1/task/create", tokenFor(t, user), "create-task-1")
+	if p := expectError(t, w, http.StatusInternalServerError, ""); p.RequestID != "create-task-1" {
+		t.Fatalf("request_id = %q, want create-task-1", p.RequestID)
+	}
+	if ids := tasks.metadata("x-request-id"); len(ids) != 1 || ids[0] != "create-task-1" {
+		t.Fatalf("x-request-id metadata = %q, want create-task-1", ids)
+	}
+}
@@ -5,24 +5,34 @@ import (
 	"api_gateway/api/handler"
 	"api_gateway/config"
 	"api_gateway/pkg/grpc_client"
+	"api_gateway/pkg/lockout"
 	"api_gateway/pkg/logger"
-	"fmt"
-	"strings"
-
+	"api_gateway/pkg/metrics"
+	"api_gateway/pkg/ratelimit"
+	"api_gateway/pkg/rbac"
+	"api_gateway/pkg/requestid"
+	"api_gateway/pkg/revocation"
+	"api_gateway/pkg/tracing"
 	"net/http"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // Config ...
 type Config struct {
 	Logger     logger.Logger
-	GrpcClient *grpc_client.GrpcClient
+	GrpcClient grpc_client.GrpcClientI
 	Cfg        config.Config
+	TokenStore revocation.Store
+	Policy     *rbac.Policy
+	Limiter    ratelimit.Limiter // rate limiting is disabled when nil
+	Lockouts   lockout.Store     // login brute-force protection is disabled when nil
+	Redis      *redis.Client     // checked by /readyz when set
 }
 
 // New ...
@@ -35,104 +45,130 @@ type Config struct {
 func New(cnf Config) *gin.Engine {
 	r := gin.New()
 
+	// c.ClientIP() believes X-Forwarded-For from these proxies only, none by default
+	if err := r.SetTrustedProxies(cnf.Cfg.TrustedProxies); err != nil {
+		cnf.Logger.Error("invalid trusted proxies, trusting none", logger.Error(err))
+		_ = r.SetTrustedProxies(nil)
+	}
+
+	if cnf.Policy == nil {
+		cnf.Policy = rbac.Default()
+	}
+
 	// r.Static("/images", "./static/images")
 
-	r.Use(gin.Logger())
+	handler := handler.New(&handler.HandlerConfig{
+		Logger:     cnf.Logger,
+		GrpcClient: cnf.GrpcClient,
+		Cfg:        cnf.Cfg,
+		TokenStore: cnf.TokenStore,
+		Policy:     cnf.Policy,
+		Limiter:    cnf.Limiter,
+		Lockouts:   cnf.Lockouts,
+		Redis:      cnf.Redis,
+	})
+
+	r.Use(handler.RequestID())
+
+	// server span per request, a child of the traceparent of the caller. The span
+	// is in the request context only until otelgin's c.Next returns, so the
+	// access log runs inside it to log the trace ID.
+	r.Use(otelgin.Middleware(tracing.ServiceName))
+
+	// access log and metrics outside Recovery, so panics are counted as the 500 they turn into
+	r.Use(handler.AccessLog())
+	r.Use(handler.Metrics())
 
 	r.Use(gin.Recovery())
 
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
 	config.AllowHeaders = append(config.AllowHeaders, "*")
+	config.ExposeHeaders = append(config.ExposeHeaders, requestid.Header)
 	// config.AllowOrigins = cnf.Cfg.AllowOrigins
 	r.Use(cors.New(config))
 
-	handler := handler.New(&handler.HandlerConfig{
-		Logger:     cnf.Logger,
-		GrpcClient: cnf.GrpcClient,
-		Cfg:        cnf.Cfg,
-	})
+	r.Use(handler.RetryBudget())
 
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"data": "Api gateway"})
 	})
-	// r.Use(authMiddleware)
-
-	///////////////////////// USER_service
-
-	r.GET("/v1/admin/getall", handler.GetAllAdmin)
-	r.GET("/v1/admin/get/:id", handler.GetAdminById)
-	r.POST("/v1/admin/create", handler.CreateAdmin)
-	r.PUT("/v1/admin/update", handler.UpdateAdmin)
-	r.DELETE("/v1/admin/delete/:id", handler.DeleteAdmin)
-	r.PATCH("/v1/admin/change_password/", handler.AdminChangePassword)
-	r.POST("/v1/admin/login", handler.AdminLogin)
-	r.POST("/v1/admin/register", handler.AdminRegister)
-	r.POST("/v1/admin/register-confirm", handler.AdminRegisterConfirm)
-
-	r.GET("/v1/user/getall", handler.GetAllUser)
-	r.GET("/v1/user/get/:id", handler.GetUserById)
-	r.POST("/v1/user/create", handler.CreateUser)
-	r.PUT("/v1/user/update", handler.UpdateUser)
-	r.DELETE("/v1/user/delete/:id", handler.DeleteUser)
-	r.PATCH("/v1/user/change_password/", handler.UserChangePassword)
-	r.POST("/v1/user/login", handler.UserLogin)
-	r.POST("/v1/user/register", handler.UserRegister)
-	r.POST("/v1/user/register-confirm", handler.UserRegisterConfirm)
-
-	r.GET("/v1/task/getall", handler.GetAllTask)
-	r.GET("/v1/task/get/:id", handler.GetTaskById)
-	r.GET("/v1/task/get_by_task_id/:id", handler.GetByExternalId)
-	r.POST("/v1/task/create", handler.CreateTask)
-	r.PUT("/v1/task/update", handler.UpdateTask)
-	r.DELETE("/v1/task/delete/:id", handler.DeleteTask)
-	r.PATCH("/v1/task/change_status/", handler.TaskChangeStatus)
+	r.GET("/healthz", handler.Healthz)
+	r.GET("/readyz", handler.Readyz)
+	r.GET("/.well-known/jwks.json", handler.JWKS)
+	if cnf.Cfg.MetricsPort == "" {
+		// otherwise cmd/main.go serves it on its own port
+		r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+
+	// public routes, reachable without an access token
+	public := r.Group("/v1", handler.RateLimit("auth", cnf.Cfg.RateLimitAuth, cnf.Cfg.RateLimitAuthKey))
+	{
+		public.POST("/admin/login", handler.AdminLogin)
+
+		public.POST("/user/login", handler.UserLogin)
+		public.POST("/user/register", handler.UserRegister)
+		public.POST("/user/register-confirm", handler.UserRegisterConfirm)
+
+		public.POST("/auth/refresh", handler.RefreshToken)
+	}
+
+	// secured routes, every request must carry a valid access token
+	// and pass the access policy (see pkg/rbac/default_policy.yaml)
+	secured := r.Group("/v1",
+		handler.AuthMiddleware(),
+		handler.RateLimit("api", cnf.Cfg.RateLimitAPI, cnf.Cfg.RateLimitAPIKey),
+		handler.Authorize(),
+	)
+	{
+		secured.POST("/auth/logout", handler.Logout)
+		secured.POST("/auth/logout-all", handler.LogoutAll)
+
+		secured.GET("/me", handler.GetMe)
+		secured.GET("/me/profile", handler.GetMyProfile)
+		secured.PUT("/me/profile", handler.UpdateMyProfile)
+		secured.PUT("/me/password", handler.ChangeMyPassword)
+		secured.GET("/me/tasks", handler.GetMyTasks)
+
+		///////////////////////// USER_service
+
+		secured.GET("/admin/getall", handler.GetAllAdmin)
+		secured.GET("/admin/get/:id", handler.GetAdminById)
+		secured.POST("/admin/create", handler.CreateAdmin)
+		secured.POST("/admin/register", handler.AdminRegister)
+		secured.POST("/admin/register-confirm", handler.AdminRegisterConfirm)
+		secured.PUT("/admin/update", handler.UpdateAdmin)
+		secured.DELETE("/admin/delete/:id", handler.DeleteAdmin)
+		secured.PATCH("/admin/change_password/", handler.AdminChangePassword)
+		secured.GET("/admin/policy", handler.GetPolicy)
+		secured.GET("/admin/lockouts", handler.GetLockouts)
+		secured.DELETE("/admin/lockouts/:key", handler.ClearLockout)
+
+		secured.GET("/debug/breakers", handler.GetBreakers)
+
+		secured.GET("/user/getall", handler.GetAllUser)
+		secured.GET("/user/get/:id", handler.GetUserById)
+		secured.POST("/user/create", handler.CreateUser)
+		secured.PUT("/user/update", handler.UpdateUser)
+		secured.DELETE("/user/delete/:id", handler.DeleteUser)
+		secured.PATCH("/user/change_password/", handler.UserChangePassword)
+
+		///////////////////////// TASK_service
+
+		secured.GET("/task/getall", handler.GetAllTask)
+		secured.GET("/task/get/:id", handler.GetTaskById)
+		secured.GET("/task/get_by_task_id/:id", handler.GetByExternalId)
+		secured.POST("/task/create", handler.CreateTask)
+		secured.PUT("/task/update", handler.UpdateTask)
+		secured.DELETE("/task/delete/:id", handler.DeleteTask)
+		secured.PATCH("/task/change_status/", handler.TaskChangeStatus)
+	}
 
 	url := ginSwagger.URL("swagger/doc.json")
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
 
-	return r
+	r.NoRoute(handler.NoRoute)
 
-}
+	return r
 
-// func authMiddleware(c *gin.Context) {
-// 	auth := c.GetHeader("Authorization")
-// 	if auth == "" {
-// 		c.AbortWithError(http.StatusUnauthorized, errors.New("unauthorized"))
-// 	}
-// 	c.Next()
-// }
-
-func JWTAuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
-			c.Abort()
-			return
-		}
-
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
-			c.Abort()
-			return
-		}
-
-		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte("your_secret_key"), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	}
 }
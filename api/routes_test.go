@@ -0,0 +1,205 @@
+package api_test
+
+import (
+	"api_gateway/config"
+	"api_gateway/pkg/jwt"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const (
+	superadmin = config.SUPERADMIN_ROLE
+	admin      = config.ADMIN_ROLE
+	user       = config.USER_ROLE
+)
+
+// routes lists every route of api.New with the roles allowed on it, public routes have none.
+var routes = []struct {
+	method, path string
+	roles        []string
+}{
+	{http.MethodGet, "/", nil},
+	{http.MethodGet, "/healthz", nil},
+	{http.MethodGet, "/readyz", nil},
+	{http.MethodGet, "/.well-known/jwks.json", nil},
+	{http.MethodGet, "/metrics", nil},
+	{http.MethodGet, "/swagger/*any", nil},
+
+	{http.MethodPost, "/v1/admin/login", nil},
+	{http.MethodPost, "/v1/user/login", nil},
+	{http.MethodPost, "/v1/user/register", nil},
+	{http.MethodPost, "/v1/user/register-confirm", nil},
+	{http.MethodPost, "/v1/auth/refresh", nil},
+
+	{http.MethodPost, "/v1/auth/logout", []string{superadmin, admin, user}},
+	{http.MethodPost, "/v1/auth/logout-all", []string{superadmin, admin, user}},
+
+	{http.MethodGet, "/v1/me", []string{superadmin, admin, user}},
+	{http.MethodGet, "/v1/me/profile", []string{superadmin, admin, user}},
+	{http.MethodPut, "/v1/me/profile", []string{superadmin, admin, user}},
+	{http.MethodPut, "/v1/me/password", []string{superadmin, admin, user}},
+	{http.MethodGet, "/v1/me/tasks", []string{superadmin, admin, user}},
+
+	{http.MethodGet, "/v1/admin/getall", []string{superadmin, admin}},
+	{http.MethodGet, "/v1/admin/get/:id", []string{superadmin, admin}},
+	{http.MethodPost, "/v1/admin/create", []string{superadmin}},
+	{http.MethodPost, "/v1/admin/register", []string{superadmin}},
+	{http.MethodPost, "/v1/admin/register-confirm", []string{superadmin}},
+	{http.MethodPut, "/v1/admin/update", []string{superadmin, admin}},
+	{http.MethodDelete, "/v1/admin/delete/:id", []string{superadmin}},
+	{http.MethodPatch, "/v1/admin/change_password/", []string{superadmin, admin}},
+	{http.MethodGet, "/v1/admin/policy", []string{superadmin, admin}},
+	{http.MethodGet, "/v1/admin/lockouts", []string{superadmin, admin}},
+	{http.MethodDelete, "/v1/admin/lockouts/:key", []string{superadmin, admin}},
+
+	{http.MethodGet, "/v1/debug/breakers", []string{superadmin, admin}},
+
+	{http.MethodGet, "/v1/user/getall", []string{superadmin, admin}},
+	{http.MethodGet, "/v1/user/get/:id", []string{superadmin, admin, user}},
+	{http.MethodPost, "/v1/user/create", []string{superadmin, admin}},
+	{http.MethodPut, "/v1/user/update", []string{superadmin, admin, user}},
+	{http.MethodDelete, "/v1/user/delete/:id", []string{superadmin, admin}},
+	{http.MethodPatch, "/v1/user/change_password/", []string{superadmin, admin, user}},
+
+	{http.MethodGet, "/v1/task/getall", []string{superadmin, admin, user}},
+	{http.MethodGet, "/v1/task/get/:id", []string{superadmin, admin, user}},
+	{http.MethodGet, "/v1/task/get_by_task_id/:id", []string{superadmin, admin, user}},
+	{http.MethodPost, "/v1/task/create", []string{superadmin, admin, user}},
+	{http.MethodPut, "/v1/task/update", []string{superadmin, admin, user}},
+	{http.MethodDelete, "/v1/task/delete/:id", []string{superadmin, admin, user}},
+	{http.MethodPatch, "/v1/task/change_status/", []string{superadmin, admin, user}},
+}
+
+// concretePath fills the parameters of a route template.
+func concretePath(path string) string {
+	path = strings.Replace(path, ":id", "00000000-0000-4000-8000-000000000000", 1)
+	path = strings.Replace(path, ":key", "user:nobody@gmail.com", 1)
+	return strings.Replace(path, "*any", "index.html", 1)
+}
+
+var tokenSeq atomic.Int64
+
+// tokenFor issues an access token of a new identity with role,
+// so logout routes do not revoke the tokens of other cases.
+func tokenFor(t *testing.T, role string) string {
+	t.Helper()
+
+	access, _, err := jwt.GenJWT(map[interface{}]interface{}{
+		"user_id":   fmt.Sprintf("route-test-%d", tokenSeq.Add(1)),
+		"user_role": role,
+	})
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	return access
+}
+
+func TestEveryRouteIsListed(t *testing.T) {
+	s := newTestServer(t)
+
+	listed := make(map[string]bool, len(routes))
+	for _, r := range routes {
+		listed[r.method+" "+r.path] = true
+	}
+
+	served := make(map[string]bool)
+	for _, r := range s.router.Routes() {
+		key := r.Method + " " + r.Path
+		served[key] = true
+		if !listed[key] {
+			t.Errorf("route %s is not covered by the tests, add it to routes", key)
+		}
+	}
+	for key := range listed {
+		if !served[key] {
+			t.Errorf("route %s is listed but not served", key)
+		}
+	}
+}
+
+func TestPublicRoutesNeedNoToken(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, r := range routes {
+		if r.roles != nil {
+			continue
+		}
+		t.Run(r.method+" "+r.path, func(t *testing.T) {
+			w := s.do(r.method, concretePath(r.path), "", nil)
+			if w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden {
+				t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSecuredRoutesRequireAccessToken(t *testing.T) {
+	s := newTestServer(t)
+
+	_, refresh, err := jwt.GenJWT(map[interface{}]interface{}{"user_id": "route-test", "user_role": user})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name, token, description string
+	}{
+		{"missing", "", "authorization header is missing"},
+		{"malformed", "not-a-jwt", "token contains an invalid number of segments"},
+		{"refresh token", refresh, "access token expected"},
+	}
+
+	for _, r := range routes {
+		if r.roles == nil {
+			continue
+		}
+		for _, tc := range cases {
+			t.Run(r.method+" "+r.path+"/"+tc.name, func(t *testing.T) {
+				expectError(t, s.do(r.method, concretePath(r.path), tc.token, nil), http.StatusUnauthorized, tc.description)
+			})
+		}
+	}
+}
+
+func TestSecuredRoutesEnforceRoles(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, r := range routes {
+		if r.roles == nil {
+			continue
+		}
+		for _, role := range []string{superadmin, admin, user} {
+			allowed := false
+			for _, allowedRole := range r.roles {
+				allowed = allowed || allowedRole == role
+			}
+
+			t.Run(r.method+" "+r.path+"/"+role, func(t *testing.T) {
+				w := s.do(r.method, concretePath(r.path), tokenFor(t, role), nil)
+				if !allowed {
+					expectError(t, w, http.StatusForbidden, "Forbidden")
+					return
+				}
+				// handlers may still refuse with their own 403, e.g. on resources of others
+				if w.Code == http.StatusForbidden && strings.Contains(w.Body.String(), `"detail":"Forbidden"`) {
+					t.Fatalf("role %s is denied by the access policy", role)
+				}
+				if w.Code == http.StatusUnauthorized {
+					t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestUnknownRoute(t *testing.T) {
+	s := newTestServer(t)
+
+	if p := expectError(t, s.do(http.MethodGet, "/v1/nothing", "", nil),
+		http.StatusNotFound, "no route for GET /v1/nothing"); p.Code != "NOT_FOUND" || p.Instance != "/v1/nothing" {
+		t.Fatalf("problem = %+v", p)
+	}
+}
@@ -0,0 +1,177 @@
+package api_test
+
+import (
+	"api_gateway/genproto/task_service"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func (s *testServer) createTask(token, title string) *task_service.GetTask {
+	s.t.Helper()
+
+	var task task_service.GetTask
+	expect(s.t, s.do(http.MethodPost, "/v1/task/create", token, map[string]string{"title": title}), http.StatusOK, &task)
+	return &task
+}
+
+func TestTaskRoutes(t *testing.T) {
+	s := newTestServer(t)
+	u := s.registerUser()
+	token := u.Tokens.AccessToken
+
+	task := s.createTask(token, "write tests")
+	if task.UserId != u.ID || task.TaskStatus != "new" || task.ExternalId == "" {
+		t.Fatalf("task = %+v, want a new task of %s", task, u.ID)
+	}
+
+	var got task_service.GetTask
+	expect(t, s.do(http.MethodGet, "/v1/task/get/"+task.Id, token, nil), http.StatusOK, &got)
+	if got.Title != "write tests" {
+		t.Fatalf("task = %+v", &got)
+	}
+	expect(t, s.do(http.MethodGet, "/v1/task/get_by_task_id/"+task.ExternalId, token, nil), http.StatusOK, &got)
+	if got.Id != task.Id {
+		t.Fatalf("task = %+v, want %s", &got, task.Id)
+	}
+
+	expect(t, s.do(http.MethodPut, "/v1/task/update", token, map[string]string{
+		"id":       task.Id,
+		"title":    "write more tests",
+		"user_id":  "someone-else",
+		"deadline": "2030-01-01T00:00:00Z",
+	}), http.StatusOK, &got)
+	if got.Title != "write more tests" || got.UserId != u.ID {
+		t.Fatalf("task = %+v, want the title updated and the owner kept", &got)
+	}
+
+	expect(t, s.do(http.MethodPatch, "/v1/task/change_status/", token, map[string]string{
+		"task_id":    task.Id,
+		"new_status": "done",
+	}), http.StatusOK, nil)
+	expect(t, s.do(http.MethodGet, "/v1/task/get/"+task.Id, token, nil), http.StatusOK, &got)
+	if got.TaskStatus != "done" {
+		t.Fatalf("status = %q, want done", got.TaskStatus)
+	}
+
+	expect(t, s.do(http.MethodDelete, "/v1/task/delete/"+task.Id, token, nil), http.StatusOK, nil)
+	expectError(t, s.do(http.MethodGet, "/v1/task/get/"+task.Id, token, nil),
+		http.StatusNotFound, "task "+task.Id+" not found")
+	expectError(t, s.do(http.MethodGet, "/v1/task/get_by_task_id/"+task.ExternalId, token, nil),
+		http.StatusNotFound, "task "+task.ExternalId+" not found")
+}
+
+func TestTaskValidation(t *testing.T) {
+	s := newTestServer(t)
+	token := s.registerUser().Tokens.AccessToken
+
+	expectError(t, s.do(http.MethodPost, "/v1/task/create", token, map[string]string{}),
+		http.StatusBadRequest, "title is required")
+
+	task := s.createTask(token, "task")
+	expectError(t, s.do(http.MethodPatch, "/v1/task/change_status/", token, map[string]string{"task_id": task.Id}),
+		http.StatusBadRequest, "new_status is required")
+}
+
+func TestTasksOfOtherUsers(t *testing.T) {
+	s := newTestServer(t)
+	owner, other := s.registerUser(), s.registerUser()
+	adminToken := s.createAdmin().Tokens.AccessToken
+	task := s.createTask(owner.Tokens.AccessToken, "private")
+	token := other.Tokens.AccessToken
+
+	for _, tc := range []struct {
+		method, path string
+		body         interface{}
+	}{
+		{http.MethodGet, "/v1/task/get/" + task.Id, nil},
+		{http.MethodGet, "/v1/task/get_by_task_id/" + task.ExternalId, nil},
+		{http.MethodPut, "/v1/task/update", map[string]string{"id": task.Id, "title": "stolen"}},
+		{http.MethodPatch, "/v1/task/change_status/", map[string]string{"task_id": task.Id, "new_status": "done"}},
+		{http.MethodDelete, "/v1/task/delete/" + task.Id, nil},
+	} {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			expectError(t, s.do(tc.method, tc.path, token, tc.body), http.StatusForbidden, "task belongs to another user")
+		})
+	}
+
+	// users see their own tasks, user_id is ignored for them
+	var list task_service.GetListTaskResponse
+	expect(t, s.do(http.MethodGet, "/v1/task/getall?user_id="+owner.ID, token, nil), http.StatusOK, &list)
+	if list.Count != 0 {
+		t.Fatalf("tasks = %+v, want none", &list)
+	}
+
+	// admins manage the tasks of everyone
+	expect(t, s.do(http.MethodGet, "/v1/task/getall?user_id="+owner.ID, adminToken, nil), http.StatusOK, &list)
+	if list.Count != 1 || list.Tasks[0].Id != task.Id {
+		t.Fatalf("tasks = %+v, want %s", &list, task.Id)
+	}
+	expect(t, s.do(http.MethodGet, "/v1/task/get/"+task.Id, adminToken, nil), http.StatusOK, nil)
+
+	var created task_service.GetTask
+	expect(t, s.do(http.MethodPost, "/v1/task/create", adminToken, map[string]string{
+		"title":   "assigned",
+		"user_id": owner.ID,
+	}), http.StatusOK, &created)
+	if created.UserId != owner.ID {
+		t.Fatalf("owner = %q, want %q", created.UserId, owner.ID)
+	}
+	expect(t, s.do(http.MethodPost, "/v1/task/create", token, map[string]string{
+		"title":   "assigned",
+		"user_id": owner.ID,
+	}), http.StatusOK, &created)
+	if created.UserId != other.ID {
+		t.Fatalf("owner = %q, want the caller %q", created.UserId, other.ID)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	s := newTestServer(t)
+	token := s.registerUser().Tokens.AccessToken
+	for i := 1; i <= 12; i++ {
+		s.createTask(token, fmt.Sprintf("task %02d", i))
+	}
+
+	for _, tc := range []struct {
+		query string
+		first string
+		count int
+	}{
+		{"", "task 01", 10},
+		{"?page=2", "task 11", 2},
+		{"?page=0", "task 01", 10},
+		{"?limit=5&page=2", "task 06", 5},
+		{"?limit=5&page=3", "task 11", 2},
+		{"?limit=0", "task 01", 10},
+		{"?page=4&limit=5", "", 0},
+		{"?search=task%201", "task 10", 3},
+	} {
+		for _, path := range []string{"/v1/me/tasks", "/v1/task/getall"} {
+			t.Run(path+tc.query, func(t *testing.T) {
+				var list task_service.GetListTaskResponse
+				expect(t, s.do(http.MethodGet, path+tc.query, token, nil), http.StatusOK, &list)
+
+				if len(list.Tasks) != tc.count {
+					t.Fatalf("got %d tasks, want %d", len(list.Tasks), tc.count)
+				}
+				if tc.count > 0 && list.Tasks[0].Title != tc.first {
+					t.Fatalf("first task = %q, want %q", list.Tasks[0].Title, tc.first)
+				}
+			})
+		}
+	}
+
+	for _, query := range []string{"?page=-1", "?page=abc", "?limit=x", "?limit=2000000000"} {
+		for _, path := range []string{"/v1/me/tasks", "/v1/task/getall", "/v1/user/getall", "/v1/admin/getall"} {
+			t.Run(path+query, func(t *testing.T) {
+				p := expectError(t, s.do(http.MethodGet, path+query, s.loginSuperadmin().AccessToken, nil),
+					http.StatusBadRequest, "invalid "+strings.Split(query[1:], "=")[0])
+				if len(p.Violations) != 1 {
+					t.Fatalf("violations = %+v, want one", p.Violations)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package api_test
+
+import (
+	"api_gateway/pkg/tracing"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	callerTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	callerSpanID      = "00f067aa0ba902b7"
+	callerTraceparent = "00-" + callerTraceID + "-" + callerSpanID + "-01"
+)
+
+// withTracing records the spans of the test in memory. Servers must be built after it.
+func withTracing(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(tracing.Propagator())
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+func (s *testServer) doWithTraceparent(method, path, token, body string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("traceparent", callerTraceparent)
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func spanNamed(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+		names = append(names, span.Name())
+	}
+	t.Fatalf("no span %q in %q", name, names)
+	return nil
+}
+
+func attributeOf(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingSpans(t *testing.T) {
+	recorder := withTracing(t)
+	s := newTestServer(t)
+	token := s.registerUser().Tokens.AccessToken
+
+	expect(t, s.doWithTraceparent(http.MethodPost, "/v1/task/create", token, `{"title":"traced"}`), http.StatusOK, nil)
+
+	server := spanNamed(t, recorder, "/v1/task/create")
+	if server.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("kind = %s, want server", server.SpanKind())
+	}
+	if got := server.SpanContext().TraceID().String(); got != callerTraceID {
+		t.Fatalf("trace id = %s, want the one of the caller", got)
+	}
+	if got := server.Parent().SpanID().String(); got != callerSpanID || !server.Parent().IsRemote() {
+		t.Fatalf("parent = %s, want the remote span of the caller", got)
+	}
+	if got := attributeOf(server, "http.route").AsString(); got != "/v1/task/create" {
+		t.Fatalf("http.route = %q", got)
+	}
+
+	client := spanNamed(t, recorder, "task_service_go.TaskService/Create")
+	if client.SpanKind() != trace.SpanKindClient {
+		t.Fatalf("kind = %s, want client", client.SpanKind())
+	}
+	if client.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatalf("parent = %s, want the server span %s", client.Parent().SpanID(), server.SpanContext().SpanID())
+	}
+	if got := attributeOf(client, "rpc.method").AsString(); got != "Create" {
+		t.Fatalf("rpc.method = %q, want Create", got)
+	}
+	if got := attributeOf(client, "rpc.grpc.status_code").AsInt64(); got != 0 || client.Status().Code == otelcodes.Error {
+		t.Fatalf("status = %d %v, want OK", got, client.Status())
+	}
+}
+
+func TestTracingPropagatesToServices(t *testing.T) {
+	recorder := withTracing(t)
+	tasks := &stubTasks{err: status.Error(codes.NotFound, "no tasks")}
+	s := newTaskStubServer(t, tasks)
+
+	expectError(t, s.doWithTraceparent(http.MethodGet, "/v1/me/tasks", tokenFor(t, user), ""), http.StatusNotFound, "no tasks")
+
+	client := spanNamed(t, recorder, "task_service_go.TaskService/GetList")
+	want := "00-" + callerTraceID + "-" + client.SpanContext().SpanID().String() + "-01"
+	if got := tasks.metadata("traceparent"); len(got) != 1 || got[0] != want {
+		t.Fatalf("traceparent = %q, want %q", got, want)
+	}
+	if client.Status().Code != otelcodes.Error || client.Status().Description != "no tasks" {
+		t.Fatalf("status = %+v, want the error of the service", client.Status())
+	}
+	if got := attributeOf(client, "rpc.grpc.status_code").AsInt64(); got != int64(codes.NotFound) {
+		t.Fatalf("rpc.grpc.status_code = %d, want %d", got, codes.NotFound)
+	}
+}
+
+func TestTracingRetriesShareTheSpan(t *testing.T) {
+	recorder := withTracing(t)
+	tasks := &stubTasks{err: status.Error(codes.Unavailable, "down")}
+	s := newTaskStubServer(t, tasks)
+
+	expectError(t, s.doWithTraceparent(http.MethodGet, "/v1/me/tasks", tokenFor(t, user), ""), http.StatusServiceUnavailable, "")
+
+	client := spanNamed(t, recorder, "task_service_go.TaskService/GetList")
+	events := client.Events()
+	if len(events) != 1 || events[0].Name != "retry" {
+		t.Fatalf("events = %+v, want one retry", events)
+	}
+}
+
+func TestTracingWithoutCaller(t *testing.T) {
+	recorder := withTracing(t)
+	s := newTestServer(t)
+
+	expect(t, s.do(http.MethodGet, "/healthz", "", nil), http.StatusOK, nil)
+
+	server := spanNamed(t, recorder, "/healthz")
+	if server.Parent().IsValid() || !server.SpanContext().IsValid() {
+		t.Fatalf("span = %+v, want a new root span", server.SpanContext())
+	}
+}
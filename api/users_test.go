@@ -0,0 +1,236 @@
+package api_test
+
+import (
+	"api_gateway/genproto/admin_service"
+	"api_gateway/genproto/user_service"
+	"api_gateway/pkg/devbackend"
+	"api_gateway/pkg/grpc_client"
+	"api_gateway/pkg/rbac"
+	"net/http"
+	"testing"
+)
+
+func TestMe(t *testing.T) {
+	s := newTestServer(t)
+	u := s.registerUser()
+
+	var profile user_service.GetUser
+	expect(t, s.do(http.MethodGet, "/v1/me/profile", u.Tokens.AccessToken, nil), http.StatusOK, &profile)
+	if profile.Id != u.ID || profile.UserLogin != u.Login {
+		t.Fatalf("profile = %+v, want user %s", &profile, u.ID)
+	}
+
+	update := map[string]string{
+		"birthday": "01-02-1990",
+		"fullname": "Renamed User",
+		"email":    u.Login,
+		"phone":    "+998907654321",
+	}
+	expect(t, s.do(http.MethodPut, "/v1/me/profile", u.Tokens.AccessToken, update), http.StatusOK, &profile)
+	if profile.Fullname != "Renamed User" || profile.Phone != "+998907654321" {
+		t.Fatalf("profile = %+v, want updated", &profile)
+	}
+
+	update["phone"] = "12345"
+	p := expectError(t, s.do(http.MethodPut, "/v1/me/profile", u.Tokens.AccessToken, update),
+		http.StatusBadRequest, "wrong phone")
+	if len(p.Violations) != 1 || p.Violations[0].Field != "phone" {
+		t.Fatalf("violations = %+v, want phone", p.Violations)
+	}
+
+	expectError(t, s.do(http.MethodPut, "/v1/me/password", u.Tokens.AccessToken, map[string]string{
+		"old_password": "wrong",
+		"new_password": "N3wPassw0rd!",
+	}), http.StatusUnauthorized, "wrong login or password")
+	expect(t, s.do(http.MethodPut, "/v1/me/password", u.Tokens.AccessToken, map[string]string{
+		"old_password": u.Password,
+		"new_password": "N3wPassw0rd!",
+	}), http.StatusOK, nil)
+	expect(t, s.do(http.MethodPost, "/v1/user/login", "", map[string]string{
+		"user_login":    u.Login,
+		"user_password": "N3wPassw0rd!",
+	}), http.StatusOK, nil)
+}
+
+func TestMeAsAdmin(t *testing.T) {
+	s := newTestServer(t)
+	token := s.loginSuperadmin().AccessToken
+
+	var profile admin_service.GetAdmin
+	expect(t, s.do(http.MethodGet, "/v1/me/profile", token, nil), http.StatusOK, &profile)
+	if profile.UserLogin != devbackend.SuperadminLogin {
+		t.Fatalf("profile = %+v, want the superadmin", &profile)
+	}
+}
+
+func TestUserRoutes(t *testing.T) {
+	s := newTestServer(t)
+	token := s.createAdmin().Tokens.AccessToken
+
+	create := map[string]string{
+		"birthday":      "01-02-1990",
+		"fullname":      "Created User",
+		"email":         newMail(),
+		"phone":         "+998901234567",
+		"user_password": "Passw0rd!",
+	}
+	var created user_service.GetUser
+	expect(t, s.do(http.MethodPost, "/v1/user/create", token, create), http.StatusOK, &created)
+
+	create["birthday"] = "01-02-2020"
+	expectError(t, s.do(http.MethodPost, "/v1/user/create", token, create),
+		http.StatusBadRequest, "wrong birthday")
+
+	var got user_service.GetUser
+	expect(t, s.do(http.MethodGet, "/v1/user/get/"+created.Id, token, nil), http.StatusOK, &got)
+	if got.Email != created.Email {
+		t.Fatalf("user = %+v, want %+v", &got, &created)
+	}
+
+	var list user_service.GetListUserResponse
+	expect(t, s.do(http.MethodGet, "/v1/user/getall?search=created", token, nil), http.StatusOK, &list)
+	if list.Count != 1 || len(list.Users) != 1 || list.Users[0].Id != created.Id {
+		t.Fatalf("users = %+v, want only %s", &list, created.Id)
+	}
+
+	expect(t, s.do(http.MethodPut, "/v1/user/update", token, map[string]string{
+		"id":       created.Id,
+		"birthday": "01-02-1990",
+		"fullname": "Updated User",
+		"email":    created.Email,
+		"phone":    created.Phone,
+	}), http.StatusOK, &got)
+	if got.Fullname != "Updated User" {
+		t.Fatalf("fullname = %q, want updated", got.Fullname)
+	}
+
+	expect(t, s.do(http.MethodPatch, "/v1/user/change_password/", token, map[string]string{
+		"UserLogin":   created.UserLogin,
+		"OldPassword": "Passw0rd!",
+		"NewPassword": "N3wPassw0rd!",
+	}), http.StatusOK, nil)
+
+	expect(t, s.do(http.MethodDelete, "/v1/user/delete/"+created.Id, token, nil), http.StatusOK, nil)
+	expectError(t, s.do(http.MethodGet, "/v1/user/get/"+created.Id, token, nil),
+		http.StatusNotFound, created.Id+" not found")
+	expectError(t, s.do(http.MethodDelete, "/v1/user/delete/"+created.Id, token, nil),
+		http.StatusNotFound, created.Id+" not found")
+}
+
+func TestUsersActOnlyOnThemselves(t *testing.T) {
+	s := newTestServer(t)
+	u, other := s.registerUser(), s.registerUser()
+	token := u.Tokens.AccessToken
+
+	expect(t, s.do(http.MethodGet, "/v1/user/get/"+u.ID, token, nil), http.StatusOK, nil)
+	expectError(t, s.do(http.MethodGet, "/v1/user/get/"+other.ID, token, nil),
+		http.StatusForbidden, "only your own profile is accessible")
+
+	expectError(t, s.do(http.MethodPut, "/v1/user/update", token, map[string]string{
+		"id":       other.ID,
+		"birthday": "01-02-1990",
+		"email":    other.Login,
+		"phone":    "+998901234567",
+	}), http.StatusForbidden, "only your own profile is accessible")
+
+	expectError(t, s.do(http.MethodPatch, "/v1/user/change_password/", token, map[string]string{
+		"UserLogin":   other.Login,
+		"OldPassword": other.Password,
+		"NewPassword": "N3wPassw0rd!",
+	}), http.StatusForbidden, "only your own profile is accessible")
+	expect(t, s.do(http.MethodPatch, "/v1/user/change_password/", token, map[string]string{
+		"UserLogin":   u.Login,
+		"OldPassword": u.Password,
+		"NewPassword": "N3wPassw0rd!",
+	}), http.StatusOK, nil)
+}
+
+func TestAdminRoutes(t *testing.T) {
+	s := newTestServer(t)
+	superToken := s.loginSuperadmin().AccessToken
+	a, other := s.createAdmin(), s.createAdmin()
+	token := a.Tokens.AccessToken
+
+	var list admin_service.GetListAdminResponse
+	expect(t, s.do(http.MethodGet, "/v1/admin/getall", token, nil), http.StatusOK, &list)
+	if list.Count != 3 {
+		t.Fatalf("admins = %d, want the superadmin and 2 admins", list.Count)
+	}
+
+	var got admin_service.GetAdmin
+	expect(t, s.do(http.MethodGet, "/v1/admin/get/"+a.ID, token, nil), http.StatusOK, &got)
+	if got.UserLogin != a.Login {
+		t.Fatalf("admin = %+v, want %s", &got, a.Login)
+	}
+	expectError(t, s.do(http.MethodGet, "/v1/admin/get/"+other.ID, token, nil),
+		http.StatusForbidden, "only your own profile is accessible")
+	expect(t, s.do(http.MethodGet, "/v1/admin/get/"+other.ID, superToken, nil), http.StatusOK, nil)
+
+	update := map[string]string{
+		"id":       a.ID,
+		"fullname": "Updated Admin",
+		"email":    a.Login,
+		"phone":    "+998901234567",
+	}
+	expect(t, s.do(http.MethodPut, "/v1/admin/update", token, update), http.StatusOK, &got)
+	if got.Fullname != "Updated Admin" {
+		t.Fatalf("fullname = %q, want updated", got.Fullname)
+	}
+	update["id"] = other.ID
+	expectError(t, s.do(http.MethodPut, "/v1/admin/update", token, update),
+		http.StatusForbidden, "only your own profile is accessible")
+
+	expectError(t, s.do(http.MethodPatch, "/v1/admin/change_password/", token, map[string]string{
+		"UserLogin":   other.Login,
+		"OldPassword": other.Password,
+		"NewPassword": "N3wPassw0rd!",
+	}), http.StatusForbidden, "only your own profile is accessible")
+	expect(t, s.do(http.MethodPatch, "/v1/admin/change_password/", token, map[string]string{
+		"UserLogin":   a.Login,
+		"OldPassword": a.Password,
+		"NewPassword": "N3wPassw0rd!",
+	}), http.StatusOK, nil)
+
+	expect(t, s.do(http.MethodDelete, "/v1/admin/delete/"+other.ID, superToken, nil), http.StatusOK, nil)
+	expectError(t, s.do(http.MethodGet, "/v1/admin/get/"+other.ID, superToken, nil),
+		http.StatusNotFound, other.ID+" not found")
+}
+
+func TestPolicyAndBreakers(t *testing.T) {
+	s := newTestServer(t)
+	token := s.createAdmin().Tokens.AccessToken
+
+	var policy rbac.Policy
+	expect(t, s.do(http.MethodGet, "/v1/admin/policy", token, nil), http.StatusOK, &policy)
+	if len(policy.Rules) == 0 {
+		t.Fatal("policy has no rules")
+	}
+
+	var breakers []grpc_client.BreakerStatus
+	expect(t, s.do(http.MethodGet, "/v1/debug/breakers", token, nil), http.StatusOK, &breakers)
+	if len(breakers) != 3 {
+		t.Fatalf("breakers = %+v, want one per service", breakers)
+	}
+	for _, b := range breakers {
+		if b.State != grpc_client.BreakerClosed {
+			t.Fatalf("breaker %s is %s", b.Service, b.State)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	s := newTestServer(t)
+
+	expect(t, s.do(http.MethodGet, "/", "", nil), http.StatusOK, nil)
+	expect(t, s.do(http.MethodGet, "/healthz", "", nil), http.StatusOK, nil)
+
+	var ready struct {
+		Status string `json:"status"`
+	}
+	expect(t, s.do(http.MethodGet, "/readyz", "", nil), http.StatusOK, &ready)
+	if ready.Status != "ready" {
+		t.Fatalf("status = %q, want ready", ready.Status)
+	}
+
+	expect(t, s.do(http.MethodGet, "/swagger/doc.json", "", nil), http.StatusOK, nil)
+}
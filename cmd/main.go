@@ -3,37 +3,291 @@ package main
 import (
 	"api_gateway/api"
 	"api_gateway/config"
+	"api_gateway/pkg/devbackend"
 	"api_gateway/pkg/grpc_client"
+	"api_gateway/pkg/jwt"
+	"api_gateway/pkg/lockout"
 	"api_gateway/pkg/logger"
+	"api_gateway/pkg/metrics"
+	"api_gateway/pkg/ratelimit"
+	"api_gateway/pkg/rbac"
+	"api_gateway/pkg/revocation"
+	"api_gateway/pkg/tracing"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
 )
 
+var devBackends = flag.Bool("dev-backends", false,
+	"serve in-memory user, admin and task services instead of dialing the real ones, implies ENVIRONMENT=local unless it is set (default with ENVIRONMENT=local)")
+
 var (
 	log        logger.Logger
 	cfg        config.Config
-	grpcClient *grpc_client.GrpcClient
+	grpcClient grpc_client.GrpcClientI
+	tokenStore revocation.Store
+	policy     *rbac.Policy
+	limiter    ratelimit.Limiter
+	lockouts   lockout.Store
+	rdb        *redis.Client
+	backends   *devbackend.Backends
+
+	shutdownTracing func(context.Context) error
 )
 
-func initDeps() {
+func initDeps(ctx context.Context) {
 	var err error
 	cfg = config.Load()
 	log = logger.New(cfg.LogLevel, "crm_api_gateway")
 
-	grpcClient, err = grpc_client.New(cfg)
+	// ENVIRONMENT defaults to production, where dev backends are refused
+	if _, set := os.LookupEnv("ENVIRONMENT"); *devBackends && !set {
+		cfg.Environment = "local"
+	}
+
+	var dialOpts []grpc.DialOption
+	if *devBackends || cfg.IsLocal() {
+		if cfg.IsProduction() {
+			log.Fatal("dev backends are not allowed in production")
+		}
+		backends, err = devbackend.Start(log)
+		if err != nil {
+			log.Fatal("dev backends error", logger.Error(err))
+		}
+		backends.Configure(&cfg)
+		dialOpts = backends.DialOptions()
+	}
+
+	traceSettings := tracing.Settings{
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		OTLPInsecure: cfg.TracingOTLPInsecure,
+		SampleRatio:  cfg.TracingSampleRatio,
+		Environment:  cfg.Environment,
+	}
+
+	log.Info("configuration", logger.Any("config", cfg.Summary()))
+	err = errors.Join(
+		cfg.Validate(),
+		rateLimitSettings(cfg).Validate(),
+		lockout.ValidateBackend(cfg.LoginLockoutBackend),
+		traceSettings.Validate(),
+	)
+	if err != nil {
+		log.Fatal("configuration error", logger.Error(err))
+	}
+
+	shutdownTracing, err = tracing.Setup(ctx, traceSettings)
+	if err != nil {
+		log.Fatal("tracing error", logger.Error(err))
+	}
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Warn("tracing export error", logger.Error(err))
+	}))
+
+	jwt.SetSigningKey([]byte(cfg.JWTSigningKey))
+
+	grpcClient, err = grpc_client.New(cfg, log, dialOpts...)
+	if err != nil {
+		log.Fatal("grpc dial error", logger.Error(err))
+	}
+
+	policy, err = rbac.Load(cfg.PolicyFile)
 	if err != nil {
-		log.Error("grpc dial error", logger.Error(err))
+		log.Fatal("access policy error", logger.Error(err))
+	}
+
+	if cfg.JWTKeysDir != "" {
+		keySet, err := jwt.LoadKeySet(cfg.JWTKeysDir, cfg.JWTKeyRotationInterval)
+		if err != nil {
+			log.Fatal("jwt keys error", logger.Error(err))
+		}
+		jwt.SetKeySet(keySet)
+		jwt.AcceptSharedSecretTokens(cfg.JWTAcceptSharedSecret)
+
+		go keySet.Run(ctx, func(err error) {
+			log.Error("jwt keys reload error", logger.Error(err))
+		})
+	}
+
+	if cfg.UsesRedis() {
+		rdb = redis.NewClient(&redis.Options{
+			Addr:     fmt.Sprintf("%s:%d", cfg.RedisHost, cfg.RedisPort),
+			Password: cfg.RedisPassword,
+		})
+	}
+
+	switch cfg.TokenStore {
+	case revocation.BackendRedis:
+		tokenStore = revocation.NewRedis(rdb)
+	default:
+		tokenStore = revocation.NewInMemory()
+	}
+
+	switch cfg.RateLimitBackend {
+	case ratelimit.BackendRedis:
+		limiter = ratelimit.NewRedis(rdb)
+	default:
+		limiter = ratelimit.NewInMemory()
+	}
+
+	switch cfg.LoginLockoutBackend {
+	case lockout.BackendRedis:
+		lockouts = lockout.NewRedis(rdb)
+	default:
+		lockouts = lockout.NewInMemory()
+	}
+
+	checkDeps(ctx)
+}
+
+func rateLimitSettings(cfg config.Config) ratelimit.Settings {
+	return ratelimit.Settings{
+		Backend:      cfg.RateLimitBackend,
+		Auth:         cfg.RateLimitAuth,
+		AuthKey:      cfg.RateLimitAuthKey,
+		API:          cfg.RateLimitAPI,
+		APIKey:       cfg.RateLimitAPIKey,
+		APIKeyHeader: cfg.RateLimitAPIKeyHeader,
+		APIKeys:      cfg.RateLimitAPIKeys,
 	}
 }
 
+// checkDeps waits for the gRPC services and Redis to become reachable. With
+// STARTUP_REQUIRE_BACKENDS the gateway refuses to start without them,
+// otherwise it starts degraded and /readyz reports the missing ones.
+func checkDeps(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.StartupTimeout)
+	defer cancel()
+
+	var errs []error
+	for {
+		errs = errs[:0]
+		for _, health := range grpcClient.CheckHealth(ctx) {
+			if health.Err != nil {
+				errs = append(errs, health.Err)
+			}
+		}
+		if rdb != nil {
+			if err := rdb.Ping(ctx).Err(); err != nil {
+				errs = append(errs, fmt.Errorf("redis: %w", err))
+			}
+		}
+
+		if len(errs) == 0 || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+
+	if len(errs) == 0 {
+		return
+	}
+	if cfg.StartupRequireBackends {
+		log.Fatal("dependencies unavailable", logger.Error(errors.Join(errs...)))
+	}
+	log.Warn("dependencies unavailable, starting degraded", logger.Error(errors.Join(errs...)))
+}
+
 func main() {
-	initDeps()
+	flag.Parse()
 
-	server := api.New(api.Config{
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	initDeps(ctx)
+
+	router := api.New(api.Config{
 		Logger:     log,
 		GrpcClient: grpcClient,
 		Cfg:        cfg,
+		TokenStore: tokenStore,
+		Policy:     policy,
+		Limiter:    limiter,
+		Lockouts:   lockouts,
+		Redis:      rdb,
 	})
 
-	server.Run(":8080")
+	server := &http.Server{
+		Addr:              ":" + cfg.HTTPPort,
+		Handler:           router,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+
+	go func() {
+		log.Info("http server started", logger.String("addr", server.Addr))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("http server error", logger.Error(err))
+		}
+	}()
+
+	// with METRICS_PORT /metrics is not on the public router but on its own listener
+	var metricsServer *http.Server
+	if cfg.MetricsPort != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		metricsServer = &http.Server{
+			Addr:              ":" + cfg.MetricsPort,
+			Handler:           mux,
+			ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		}
+
+		go func() {
+			log.Info("metrics server started", logger.String("addr", metricsServer.Addr))
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal("metrics server error", logger.Error(err))
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	stop()
+	log.Info("shutting down, draining in-flight requests", logger.String("timeout", cfg.ShutdownTimeout.String()))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("http server shutdown error", logger.Error(err))
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			log.Error("metrics server shutdown error", logger.Error(err))
+		}
+	}
+	if err := grpcClient.Close(); err != nil {
+		log.Error("grpc close error", logger.Error(err))
+	}
+	if rdb != nil {
+		if err := rdb.Close(); err != nil {
+			log.Error("redis close error", logger.Error(err))
+		}
+	}
+	if backends != nil {
+		backends.Stop()
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error("tracing shutdown error", logger.Error(err))
+	}
+
+	log.Info("stopped")
+	logger.Cleanup(log)
 }
 
 // go build -ldflags "-X google.golang.org/protobuf/reflect/protoregistry.conflictPolicy=warn"
@@ -3,27 +3,149 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/cast"
 )
 
-const ()
+const (
+	// insecureJWTSigningKey is the development default of JWTSigningKey, refused in production.
+	insecureJWTSigningKey = "insecure-development-signing-key"
+)
+
+// TLS modes of the connections to the gRPC services.
+const (
+	TLSModePlaintext = "plaintext"
+	TLSModeTLS       = "tls"
+	TLSModeMTLS      = "mtls"
+)
+
+// TLSConfig is the transport security of the connection to a gRPC service.
+// Files are reloaded when they change on disk.
+type TLSConfig struct {
+	Mode       string // plaintext, tls, mtls
+	CAFile     string // PEM CA bundle to verify the service, the system pool when empty
+	CertFile   string // PEM client certificate, mtls only
+	KeyFile    string // PEM client key, mtls only
+	ServerName string // overrides the name verified in the service certificate
+}
+
+// Load balancing policies across the replicas of a gRPC service.
+const (
+	LBPolicyPickFirst    = "pick_first"
+	LBPolicyRoundRobin   = "round_robin"
+	LBPolicyLeastRequest = "least_request"
+)
+
+// LBConfig is the load balancing across the replicas of a gRPC service.
+type LBConfig struct {
+	Policy string // pick_first, round_robin, least_request
+	// HealthCheck ejects replicas that fail the gRPC health checking protocol,
+	// not supported by pick_first, which is refused with it.
+	HealthCheck bool
+}
 
 // Config ...
 type Config struct {
-	Environment   string // develop, staging, production
+	Environment   string // local, develop, staging, production
 	RedisHost     string
 	RedisPort     int
-	RedisPassword string
+	RedisPassword string // secret
+
+	// JWTSigningKey is the HS256 secret shared with the backend services.
+	JWTSigningKey string // secret
+
+	SMTPServer   string
+	SMTPPort     string
+	SMTPUsername string // secret
+	SMTPPassword string // secret
+
+	TokenStore string // memory, redis
+	PolicyFile string // YAML access policy, the embedded default when empty
+
+	JWTKeysDir             string        // directory of RSA/Ed25519 *.pem signing keys, HS256 when empty
+	JWTKeyRotationInterval time.Duration // how often JWTKeysDir is rescanned and a newer key activated
+	JWTAcceptSharedSecret  bool          // accept HS256 tokens without kid along JWTKeysDir, while migrating to it
+
+	RateLimitBackend string // memory, redis
+	RateLimitAuth    string // "<requests>/<period>" for login, register and refresh, "off" to disable
+	RateLimitAuthKey string // ip, user, api_key
+	RateLimitAPI     string // "<requests>/<period>" for secured routes, "off" to disable
+	RateLimitAPIKey  string // ip, user, api_key
+
+	RateLimitAPIKeyHeader string   // header carrying the API key in the api_key mode
+	RateLimitAPIKeys      []string // hex SHA-256 digests of the API keys limited on their own, others by address
 
-	UserServiceHost string
-	UserServicePort string
-	TaskServiceHost string
-	TaskServicePort string
+	LoginLockoutBackend  string        // memory, redis
+	LoginMaxFailures     int           // failed logins of one login name before it is locked
+	LoginIPMaxFailures   int           // failed logins from one address before it is locked
+	LoginLockoutDuration time.Duration // how long a lockout lasts
+	LoginDelayBase       time.Duration // delay after the second failure, doubled with every further one
+	LoginDelayMax        time.Duration
+	LoginFailureWindow   time.Duration // failures older than this are forgotten
+	LoginMaxInFlight     int           // pending logins of one login name, 0 is unlimited
+	LoginIPMaxInFlight   int           // pending logins from one address, 0 is unlimited
+
+	BreakerFailureThreshold int           // consecutive failures that open the circuit breaker of a service
+	BreakerOpenTimeout      time.Duration // how long an open breaker fails fast before probing
+	BreakerHalfOpenProbes   int           // successful probes that close the breaker again
+
+	GrpcRetryMaxAttempts    int           // attempts of an idempotent call, including the first one
+	GrpcRetryInitialBackoff time.Duration // upper bound of the first wait, doubled per retry
+	GrpcRetryMaxBackoff     time.Duration
+	GrpcRetryBudget         int // retries of all calls of one HTTP request
+
+	// GrpcTimeout is the deadline of a gRPC call without a more specific entry in GrpcTimeouts.
+	GrpcTimeout time.Duration
+	// GrpcTimeouts are deadlines by "<service>.<Method>", "<Method>" or "<service>",
+	// e.g. GRPC_TIMEOUTS="GetByID=2s,GetList=10s,task_service=3s".
+	GrpcTimeouts map[string]time.Duration
+
+	// A service is reached at the replicas in <SERVICE>_ADDRS ("host:port,host:port")
+	// or at all addresses <SERVICE>_HOST resolves to.
+	UserServiceHost  string
+	UserServicePort  string
+	UserServiceAddrs []string
+	UserServiceLB    LBConfig
+	UserServiceTLS   TLSConfig
+	TaskServiceHost  string
+	TaskServicePort  string
+	TaskServiceAddrs []string
+	TaskServiceLB    LBConfig
+	TaskServiceTLS   TLSConfig
 
 	LogLevel string
 	HTTPPort string
+
+	// TrustedProxies are the addresses and CIDRs of the reverse proxies whose
+	// X-Forwarded-For is believed. Client addresses key rate limits and login
+	// lockouts, so by default no proxy is trusted and clients cannot pick them.
+	TrustedProxies []string
+
+	TracingExporter     string  // none, stdout, otlp
+	TracingOTLPEndpoint string  // host:port of the OTLP gRPC collector
+	TracingOTLPInsecure bool    // plaintext connection to the collector
+	TracingSampleRatio  float64 // share of the new traces that are sampled, the callers' decisions are kept
+
+	// MetricsPort serves /metrics on a listener of its own, to keep it off
+	// the public network. When empty /metrics is served on HTTPPort.
+	MetricsPort string
+
+	HTTPReadTimeout       time.Duration
+	HTTPReadHeaderTimeout time.Duration
+	HTTPWriteTimeout      time.Duration
+	HTTPIdleTimeout       time.Duration
+	ShutdownTimeout       time.Duration // how long in-flight requests may drain on SIGTERM
+
+	// StartupRequireBackends refuses to start while the gRPC services or Redis
+	// are unreachable, otherwise the gateway starts degraded and /readyz fails.
+	StartupRequireBackends bool
+	StartupTimeout         time.Duration // how long the startup check waits for the dependencies
+
+	// loadErrs are problems found by Load, reported by Validate.
+	loadErrs []error
 }
 
 // Load loads environment vars and inflates Config
@@ -38,9 +160,69 @@ func Load() Config {
 
 	c.LogLevel = cast.ToString(getOrReturnDefault("LOG_LEVEL", "debug"))
 	c.HTTPPort = cast.ToString(getOrReturnDefault("HTTP_PORT", "8080"))
+	c.TrustedProxies = getList("TRUSTED_PROXIES")
+	c.HTTPReadTimeout = cast.ToDuration(getOrReturnDefault("HTTP_READ_TIMEOUT", "15s"))
+	c.HTTPReadHeaderTimeout = cast.ToDuration(getOrReturnDefault("HTTP_READ_HEADER_TIMEOUT", "5s"))
+	c.HTTPWriteTimeout = cast.ToDuration(getOrReturnDefault("HTTP_WRITE_TIMEOUT", "30s"))
+	c.HTTPIdleTimeout = cast.ToDuration(getOrReturnDefault("HTTP_IDLE_TIMEOUT", "2m"))
+	c.ShutdownTimeout = cast.ToDuration(getOrReturnDefault("SHUTDOWN_TIMEOUT", "30s"))
+	c.StartupRequireBackends = cast.ToBool(getOrReturnDefault("STARTUP_REQUIRE_BACKENDS", true))
+	c.StartupTimeout = cast.ToDuration(getOrReturnDefault("STARTUP_TIMEOUT", "10s"))
+
+	c.TracingExporter = cast.ToString(getOrReturnDefault("TRACING_EXPORTER", "none"))
+	c.TracingOTLPEndpoint = cast.ToString(getOrReturnDefault("TRACING_OTLP_ENDPOINT", "localhost:4317"))
+	c.TracingOTLPInsecure = cast.ToBool(getOrReturnDefault("TRACING_OTLP_INSECURE", true))
+	c.TracingSampleRatio = cast.ToFloat64(getOrReturnDefault("TRACING_SAMPLE_RATIO", 1.0))
+
+	c.MetricsPort = cast.ToString(getOrReturnDefault("METRICS_PORT", ""))
+
 	c.RedisHost = cast.ToString(getOrReturnDefault("REDIS_HOST", "127.0.0.1"))
 	c.RedisPort = cast.ToInt(getOrReturnDefault("REDIS_PORT", 6379))
-	c.RedisPassword = cast.ToString(getOrReturnDefault("REDIS_PASSWORD", "3EEdwhDOfx"))
+	c.RedisPassword = c.getSecret("REDIS_PASSWORD", "")
+
+	c.JWTSigningKey = c.getSecret("JWT_SIGNING_KEY", insecureJWTSigningKey)
+
+	c.SMTPServer = cast.ToString(getOrReturnDefault("SMTP_SERVER", "smtp.gmail.com"))
+	c.SMTPPort = cast.ToString(getOrReturnDefault("SMTP_PORT", "587"))
+	c.SMTPUsername = c.getSecret("SMTP_USERNAME", "")
+	c.SMTPPassword = c.getSecret("SMTP_PASSWORD", "")
+
+	c.TokenStore = cast.ToString(getOrReturnDefault("TOKEN_STORE", "memory"))
+	c.PolicyFile = cast.ToString(getOrReturnDefault("POLICY_FILE", ""))
+
+	c.JWTKeysDir = cast.ToString(getOrReturnDefault("JWT_KEYS_DIR", ""))
+	c.JWTKeyRotationInterval = cast.ToDuration(getOrReturnDefault("JWT_KEY_ROTATION_INTERVAL", "1h"))
+	c.JWTAcceptSharedSecret = cast.ToBool(getOrReturnDefault("JWT_ACCEPT_SHARED_SECRET", false))
+
+	c.RateLimitBackend = cast.ToString(getOrReturnDefault("RATE_LIMIT_BACKEND", "memory"))
+	c.RateLimitAuth = cast.ToString(getOrReturnDefault("RATE_LIMIT_AUTH", "10/1m"))
+	c.RateLimitAuthKey = cast.ToString(getOrReturnDefault("RATE_LIMIT_AUTH_KEY", "ip"))
+	c.RateLimitAPI = cast.ToString(getOrReturnDefault("RATE_LIMIT_API", "300/1m"))
+	c.RateLimitAPIKey = cast.ToString(getOrReturnDefault("RATE_LIMIT_API_KEY", "user"))
+	c.RateLimitAPIKeyHeader = cast.ToString(getOrReturnDefault("RATE_LIMIT_API_KEY_HEADER", "X-API-Key"))
+	c.RateLimitAPIKeys = getList("RATE_LIMIT_API_KEYS")
+
+	c.LoginLockoutBackend = cast.ToString(getOrReturnDefault("LOGIN_LOCKOUT_BACKEND", "memory"))
+	c.LoginMaxFailures = cast.ToInt(getOrReturnDefault("LOGIN_MAX_FAILURES", 5))
+	c.LoginIPMaxFailures = cast.ToInt(getOrReturnDefault("LOGIN_IP_MAX_FAILURES", 20))
+	c.LoginLockoutDuration = cast.ToDuration(getOrReturnDefault("LOGIN_LOCKOUT_DURATION", "15m"))
+	c.LoginDelayBase = cast.ToDuration(getOrReturnDefault("LOGIN_DELAY_BASE", "1s"))
+	c.LoginDelayMax = cast.ToDuration(getOrReturnDefault("LOGIN_DELAY_MAX", "30s"))
+	c.LoginFailureWindow = cast.ToDuration(getOrReturnDefault("LOGIN_FAILURE_WINDOW", "15m"))
+	c.LoginMaxInFlight = cast.ToInt(getOrReturnDefault("LOGIN_MAX_IN_FLIGHT", 2))
+	c.LoginIPMaxInFlight = cast.ToInt(getOrReturnDefault("LOGIN_IP_MAX_IN_FLIGHT", 10))
+
+	c.BreakerFailureThreshold = cast.ToInt(getOrReturnDefault("BREAKER_FAILURE_THRESHOLD", 5))
+	c.BreakerOpenTimeout = cast.ToDuration(getOrReturnDefault("BREAKER_OPEN_TIMEOUT", "30s"))
+	c.BreakerHalfOpenProbes = cast.ToInt(getOrReturnDefault("BREAKER_HALF_OPEN_PROBES", 1))
+
+	c.GrpcRetryMaxAttempts = cast.ToInt(getOrReturnDefault("GRPC_RETRY_MAX_ATTEMPTS", 3))
+	c.GrpcRetryInitialBackoff = cast.ToDuration(getOrReturnDefault("GRPC_RETRY_INITIAL_BACKOFF", "100ms"))
+	c.GrpcRetryMaxBackoff = cast.ToDuration(getOrReturnDefault("GRPC_RETRY_MAX_BACKOFF", "1s"))
+	c.GrpcRetryBudget = cast.ToInt(getOrReturnDefault("GRPC_RETRY_BUDGET", 3))
+
+	c.GrpcTimeout = cast.ToDuration(getOrReturnDefault("GRPC_TIMEOUT", "5s"))
+	c.GrpcTimeouts = c.getDurations("GRPC_TIMEOUTS", "GetByID=2s,GetByExternalId=2s,GetList=10s")
 
 	c.TaskServiceHost = cast.ToString(getOrReturnDefault("TASK_SERVICE_HOST", "localhost"))
 	c.TaskServicePort = cast.ToString(getOrReturnDefault("TASK_SERVICE_PORT", "8082"))
@@ -48,6 +230,15 @@ func Load() Config {
 	c.UserServiceHost = cast.ToString(getOrReturnDefault("USER_SERVICE_HOST", "localhost"))
 	c.UserServicePort = cast.ToString(getOrReturnDefault("USER_SERVICE_PORT", "8081"))
 
+	c.TaskServiceAddrs = getList("TASK_SERVICE_ADDRS")
+	c.UserServiceAddrs = getList("USER_SERVICE_ADDRS")
+
+	c.TaskServiceLB = getLBConfig("TASK_SERVICE")
+	c.UserServiceLB = getLBConfig("USER_SERVICE")
+
+	c.TaskServiceTLS = getTLSConfig("TASK_SERVICE")
+	c.UserServiceTLS = getTLSConfig("USER_SERVICE")
+
 	return c
 }
 
@@ -58,3 +249,69 @@ func getOrReturnDefault(key string, defaultValue interface{}) interface{} {
 
 	return os.Getenv(key)
 }
+
+// getList reads a comma separated list.
+func getList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getLBConfig reads the <prefix>_LB_POLICY and <prefix>_HEALTH_CHECK variables.
+func getLBConfig(prefix string) LBConfig {
+	return LBConfig{
+		Policy:      cast.ToString(getOrReturnDefault(prefix+"_LB_POLICY", LBPolicyRoundRobin)),
+		HealthCheck: cast.ToBool(getOrReturnDefault(prefix+"_HEALTH_CHECK", true)),
+	}
+}
+
+// getTLSConfig reads the <prefix>_TLS_* variables.
+func getTLSConfig(prefix string) TLSConfig {
+	return TLSConfig{
+		Mode:       cast.ToString(getOrReturnDefault(prefix+"_TLS_MODE", TLSModePlaintext)),
+		CAFile:     cast.ToString(getOrReturnDefault(prefix+"_TLS_CA", "")),
+		CertFile:   cast.ToString(getOrReturnDefault(prefix+"_TLS_CERT", "")),
+		KeyFile:    cast.ToString(getOrReturnDefault(prefix+"_TLS_KEY", "")),
+		ServerName: cast.ToString(getOrReturnDefault(prefix+"_TLS_SERVER_NAME", "")),
+	}
+}
+
+// getSecret reads a secret from the file named by <key>_FILE (Docker/K8s secret
+// mounts) or, when that is not set, from the <key> environment variable.
+func (c *Config) getSecret(key string, defaultValue string) string {
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			c.loadErrs = append(c.loadErrs, fmt.Errorf("%s_FILE: %w", key, err))
+			return ""
+		}
+		return strings.TrimRight(string(data), "\r\n")
+	}
+
+	return cast.ToString(getOrReturnDefault(key, defaultValue))
+}
+
+// getDurations reads a comma separated list of <name>=<duration> pairs.
+func (c *Config) getDurations(key string, defaultValue string) map[string]time.Duration {
+	durations := make(map[string]time.Duration)
+
+	for _, pair := range strings.Split(cast.ToString(getOrReturnDefault(key, defaultValue)), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || err != nil || d <= 0 {
+			c.loadErrs = append(c.loadErrs, fmt.Errorf("%s: %q is not <name>=<positive duration>", key, pair))
+			continue
+		}
+		durations[strings.TrimSpace(name)] = d
+	}
+	return durations
+}
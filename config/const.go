@@ -6,12 +6,7 @@ const (
 	ERR_REDIRECTION     = "You have been redirected and the completion of the request requires further action"
 	ERR_BADREQUEST      = "Bad request"
 	ERR_INTERNAL_SERVER = "While the request appears to be valid, the server could not complete the request"
+	SUPERADMIN_ROLE     = "superadmin"
 	ADMIN_ROLE          = "admin"
 	USER_ROLE           = "user"
-	SmtpServer          = "smtp.gmail.com"
-	SmtpPort            = "587"
-	SmtpUsername        = "mirsadikovmirodil52@gmail.com"
-	SmtpPassword        = "mwzz ekyq ybqc wuzd"
 )
-
-var SignedKey = []byte("MGJd@Ro]yKoCc)mVY1^c:upz~4rn9Pt!hYd]>c8dt#+%")
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// minJWTSigningKeyLen is the shortest HS256 secret accepted in production.
+const minJWTSigningKeyLen = 32
+
+// IsProduction reports whether the gateway runs in the production environment.
+func (c Config) IsProduction() bool {
+	return c.Environment == "prod" || c.Environment == "production"
+}
+
+// IsLocal reports whether the gateway runs on a developer machine,
+// where it serves the in-memory dev backends instead of the real services.
+func (c Config) IsLocal() bool {
+	return c.Environment == "local"
+}
+
+// Validate checks the configuration before the gateway starts. In production
+// it also refuses insecure defaults, missing secrets and plaintext connections.
+//
+// Settings handed to a package as they are, such as the rate limits, the
+// lockout backend and the trace exporter, are checked by that package.
+func (c Config) Validate() error {
+	errs := append([]error{}, c.loadErrs...)
+
+	if c.HTTPPort == "" {
+		errs = append(errs, errors.New("HTTP_PORT is empty"))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("TRUSTED_PROXIES: %q is neither an address nor a CIDR", proxy))
+		}
+	}
+	if c.MetricsPort != "" && c.MetricsPort == c.HTTPPort {
+		errs = append(errs, errors.New("METRICS_PORT must differ from HTTP_PORT, leave it empty to serve /metrics on HTTP_PORT"))
+	}
+	if c.HTTPReadTimeout <= 0 || c.HTTPReadHeaderTimeout <= 0 || c.HTTPWriteTimeout <= 0 || c.HTTPIdleTimeout <= 0 {
+		errs = append(errs, errors.New("HTTP_READ_TIMEOUT, HTTP_READ_HEADER_TIMEOUT, HTTP_WRITE_TIMEOUT and HTTP_IDLE_TIMEOUT must be positive"))
+	}
+	if c.ShutdownTimeout <= 0 || c.StartupTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT and STARTUP_TIMEOUT must be positive"))
+	}
+	if c.TokenStore != "memory" && c.TokenStore != "redis" {
+		errs = append(errs, fmt.Errorf("TOKEN_STORE must be memory or redis, got %q", c.TokenStore))
+	}
+	if c.JWTKeysDir != "" && c.JWTKeyRotationInterval <= 0 {
+		errs = append(errs, errors.New("JWT_KEY_ROTATION_INTERVAL must be positive"))
+	}
+	if c.JWTSigningKey == "" {
+		errs = append(errs, errors.New("JWT_SIGNING_KEY is empty"))
+	}
+	if c.LoginMaxFailures <= 0 || c.LoginIPMaxFailures <= 0 {
+		errs = append(errs, errors.New("LOGIN_MAX_FAILURES and LOGIN_IP_MAX_FAILURES must be positive"))
+	}
+	if c.LoginLockoutDuration <= 0 || c.LoginFailureWindow <= 0 {
+		errs = append(errs, errors.New("LOGIN_LOCKOUT_DURATION and LOGIN_FAILURE_WINDOW must be positive"))
+	}
+	if c.LoginDelayBase < 0 || c.LoginDelayMax < c.LoginDelayBase {
+		errs = append(errs, errors.New("LOGIN_DELAY_MAX must not be less than LOGIN_DELAY_BASE"))
+	}
+	if c.LoginMaxInFlight < 0 || c.LoginIPMaxInFlight < 0 {
+		errs = append(errs, errors.New("LOGIN_MAX_IN_FLIGHT and LOGIN_IP_MAX_IN_FLIGHT must not be negative"))
+	}
+	if c.BreakerFailureThreshold <= 0 || c.BreakerHalfOpenProbes <= 0 || c.BreakerOpenTimeout <= 0 {
+		errs = append(errs, errors.New("BREAKER_FAILURE_THRESHOLD, BREAKER_OPEN_TIMEOUT and BREAKER_HALF_OPEN_PROBES must be positive"))
+	}
+	if c.GrpcRetryMaxAttempts <= 0 {
+		errs = append(errs, errors.New("GRPC_RETRY_MAX_ATTEMPTS must be positive"))
+	}
+	if c.GrpcRetryBudget < 0 || c.GrpcRetryInitialBackoff < 0 || c.GrpcRetryMaxBackoff < c.GrpcRetryInitialBackoff {
+		errs = append(errs, errors.New("GRPC_RETRY_BUDGET must not be negative and GRPC_RETRY_MAX_BACKOFF not less than GRPC_RETRY_INITIAL_BACKOFF"))
+	}
+	errs = append(errs, validateAddrs("USER_SERVICE_ADDRS", c.UserServiceAddrs)...)
+	errs = append(errs, validateAddrs("TASK_SERVICE_ADDRS", c.TaskServiceAddrs)...)
+	errs = append(errs, c.UserServiceLB.validate("USER_SERVICE")...)
+	errs = append(errs, c.TaskServiceLB.validate("TASK_SERVICE")...)
+	errs = append(errs, c.UserServiceTLS.validate("USER_SERVICE", c.UserServiceAddrs)...)
+	errs = append(errs, c.TaskServiceTLS.validate("TASK_SERVICE", c.TaskServiceAddrs)...)
+	if c.GrpcTimeout <= 0 {
+		errs = append(errs, errors.New("GRPC_TIMEOUT must be positive"))
+	}
+
+	if c.IsProduction() {
+		if c.JWTSigningKey == insecureJWTSigningKey {
+			errs = append(errs, errors.New("JWT_SIGNING_KEY uses the insecure development default"))
+		} else if len(c.JWTSigningKey) < minJWTSigningKeyLen {
+			errs = append(errs, fmt.Errorf("JWT_SIGNING_KEY must be at least %d bytes", minJWTSigningKeyLen))
+		}
+		if c.UsesRedis() && c.RedisPassword == "" {
+			errs = append(errs, errors.New("REDIS_PASSWORD is empty"))
+		}
+		// passwords, OTPs and tokens cross these connections
+		if c.UserServiceTLS.Mode == TLSModePlaintext || c.TaskServiceTLS.Mode == TLSModePlaintext {
+			errs = append(errs, errors.New("USER_SERVICE_TLS_MODE and TASK_SERVICE_TLS_MODE must be tls or mtls"))
+		}
+		if c.TracingExporter == "otlp" && c.TracingOTLPInsecure {
+			errs = append(errs, errors.New("TRACING_OTLP_INSECURE must be false, spans carry request paths and user IDs"))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid %s configuration: %w", c.Environment, errors.Join(errs...))
+	}
+	return nil
+}
+
+// Summary returns the configuration for logging at startup, with secrets redacted.
+func (c Config) Summary() map[string]interface{} {
+	return map[string]interface{}{
+		"environment":               c.Environment,
+		"log_level":                 c.LogLevel,
+		"http_port":                 c.HTTPPort,
+		"trusted_proxies":           c.TrustedProxies,
+		"metrics":                   c.metricsSummary(),
+		"http_timeouts":             fmt.Sprintf("read %s, read header %s, write %s, idle %s", c.HTTPReadTimeout, c.HTTPReadHeaderTimeout, c.HTTPWriteTimeout, c.HTTPIdleTimeout),
+		"shutdown_timeout":          c.ShutdownTimeout.String(),
+		"startup_require_backends":  c.StartupRequireBackends,
+		"redis_host":                c.RedisHost,
+		"redis_port":                c.RedisPort,
+		"redis_password":            redact(c.RedisPassword),
+		"token_store":               c.TokenStore,
+		"policy_file":               c.PolicyFile,
+		"jwt_signing_key":           redact(c.JWTSigningKey),
+		"jwt_keys_dir":              c.JWTKeysDir,
+		"jwt_key_rotation_interval": c.JWTKeyRotationInterval.String(),
+		"jwt_accept_shared_secret":  c.JWTAcceptSharedSecret,
+		"rate_limit_backend":        c.RateLimitBackend,
+		"rate_limit_auth":           c.RateLimitAuth + " by " + c.RateLimitAuthKey,
+		"rate_limit_api":            c.RateLimitAPI + " by " + c.RateLimitAPIKey,
+		"rate_limit_api_keys":       fmt.Sprintf("%d in %s", len(c.RateLimitAPIKeys), c.RateLimitAPIKeyHeader),
+		"login_lockout_backend":     c.LoginLockoutBackend,
+		"login_max_failures":        c.LoginMaxFailures,
+		"login_ip_max_failures":     c.LoginIPMaxFailures,
+		"login_lockout_duration":    c.LoginLockoutDuration.String(),
+		"login_max_in_flight":       fmt.Sprintf("%d per login name, %d per address", c.LoginMaxInFlight, c.LoginIPMaxInFlight),
+		"grpc_retry":                fmt.Sprintf("%d attempts, %s-%s backoff, %d per request", c.GrpcRetryMaxAttempts, c.GrpcRetryInitialBackoff, c.GrpcRetryMaxBackoff, c.GrpcRetryBudget),
+		"grpc_timeout":              c.GrpcTimeout.String(),
+		"grpc_timeouts":             c.GrpcTimeouts,
+		"tracing":                   c.tracingSummary(),
+		"breaker":                   fmt.Sprintf("%d failures, %s open, %d probes", c.BreakerFailureThreshold, c.BreakerOpenTimeout, c.BreakerHalfOpenProbes),
+		"smtp_server":               c.SMTPServer,
+		"smtp_port":                 c.SMTPPort,
+		"smtp_username":             redact(c.SMTPUsername),
+		"smtp_password":             redact(c.SMTPPassword),
+		"user_service":              serviceAddrs(c.UserServiceHost, c.UserServicePort, c.UserServiceAddrs),
+		"user_service_lb":           c.UserServiceLB,
+		"user_service_tls":          c.UserServiceTLS.Mode,
+		"task_service":              serviceAddrs(c.TaskServiceHost, c.TaskServicePort, c.TaskServiceAddrs),
+		"task_service_lb":           c.TaskServiceLB,
+		"task_service_tls":          c.TaskServiceTLS.Mode,
+	}
+}
+
+func (c Config) tracingSummary() string {
+	switch c.TracingExporter {
+	case "none":
+		return c.TracingExporter
+	case "otlp":
+		return fmt.Sprintf("otlp to %s (insecure %t), sample ratio %g", c.TracingOTLPEndpoint, c.TracingOTLPInsecure, c.TracingSampleRatio)
+	}
+	return fmt.Sprintf("%s, sample ratio %g", c.TracingExporter, c.TracingSampleRatio)
+}
+
+func (c Config) metricsSummary() string {
+	if c.MetricsPort == "" {
+		return "/metrics on http_port"
+	}
+	return "/metrics on port " + c.MetricsPort
+}
+
+// UsesRedis reports whether any store is configured with the Redis backend.
+func (c Config) UsesRedis() bool {
+	return c.TokenStore == "redis" ||
+		c.RateLimitBackend == "redis" ||
+		c.LoginLockoutBackend == "redis"
+}
+
+func (l LBConfig) validate(prefix string) []error {
+	switch l.Policy {
+	case LBPolicyPickFirst:
+		if l.HealthCheck {
+			// grpc ignores the health checking config of pick_first
+			return []error{fmt.Errorf("%s_HEALTH_CHECK is not supported by pick_first, disable it or use round_robin or least_request", prefix)}
+		}
+		return nil
+	case LBPolicyRoundRobin, LBPolicyLeastRequest:
+		return nil
+	}
+	return []error{fmt.Errorf("%s_LB_POLICY must be pick_first, round_robin or least_request, got %q", prefix, l.Policy)}
+}
+
+func validateAddrs(name string, addrs []string) []error {
+	var errs []error
+	for _, addr := range addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+func serviceAddrs(host, port string, addrs []string) string {
+	if len(addrs) > 0 {
+		return strings.Join(addrs, ",")
+	}
+	return host + ":" + port
+}
+
+func (t TLSConfig) validate(prefix string, addrs []string) []error {
+	var errs []error
+	if t.Mode != TLSModePlaintext && len(addrs) > 0 && t.ServerName == "" {
+		// replicas of a static list have no common host name to verify
+		errs = append(errs, fmt.Errorf("%s_TLS_SERVER_NAME is required with %s_ADDRS", prefix, prefix))
+	}
+	switch t.Mode {
+	case TLSModePlaintext, TLSModeTLS:
+	case TLSModeMTLS:
+		if t.CertFile == "" || t.KeyFile == "" {
+			errs = append(errs, fmt.Errorf("%s_TLS_CERT and %s_TLS_KEY are required in mtls mode", prefix, prefix))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%s_TLS_MODE must be plaintext, tls or mtls, got %q", prefix, t.Mode))
+	}
+	return errs
+}
+
+func redact(secret string) string {
+	switch secret {
+	case "":
+		return "<empty>"
+	case insecureJWTSigningKey:
+		return "<insecure default>"
+	}
+	return "<redacted>"
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLBConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		lb    LBConfig
+		valid bool
+	}{
+		{LBConfig{Policy: LBPolicyPickFirst}, true},
+		{LBConfig{Policy: LBPolicyPickFirst, HealthCheck: true}, false},
+		{LBConfig{Policy: LBPolicyRoundRobin, HealthCheck: true}, true},
+		{LBConfig{Policy: LBPolicyLeastRequest, HealthCheck: true}, true},
+		{LBConfig{Policy: "random"}, false},
+	} {
+		if errs := tc.lb.validate("TASK_SERVICE"); (len(errs) == 0) != tc.valid {
+			t.Errorf("%+v: errors = %v, want valid %v", tc.lb, errs, tc.valid)
+		}
+	}
+}
+
+func TestProductionRefusesPlaintext(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		change  func(c *Config)
+		refused string
+	}{
+		{"plaintext user service", func(c *Config) { c.UserServiceTLS.Mode = TLSModePlaintext }, "TLS_MODE"},
+		{"plaintext task service", func(c *Config) { c.TaskServiceTLS.Mode = TLSModePlaintext }, "TLS_MODE"},
+		{"insecure otlp", func(c *Config) { c.TracingExporter, c.TracingOTLPInsecure = "otlp", true }, "TRACING_OTLP_INSECURE"},
+		{"secure otlp", func(c *Config) { c.TracingExporter = "otlp" }, ""},
+	} {
+		c := Config{
+			Environment:    "prod",
+			JWTSigningKey:  "production-signing-key-0123456789abcdef",
+			TokenStore:     "memory",
+			UserServiceTLS: TLSConfig{Mode: TLSModeTLS},
+			TaskServiceTLS: TLSConfig{Mode: TLSModeMTLS, CertFile: "client.pem", KeyFile: "client-key.pem"},
+		}
+		tc.change(&c)
+
+		err := c.Validate()
+		for _, name := range []string{"TLS_MODE", "TRACING_OTLP_INSECURE"} {
+			if got := err != nil && strings.Contains(err.Error(), name); got != (name == tc.refused) {
+				t.Errorf("%s: error = %v, want %s refused %v", tc.name, err, name, name == tc.refused)
+			}
+		}
+	}
+}
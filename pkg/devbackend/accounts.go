@@ -0,0 +1,232 @@
+package devbackend
+
+import (
+	"api_gateway/pkg/requestid"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// account is a user or an admin, both services keep the same fields.
+type account struct {
+	ID        string
+	Login     string
+	Birthday  string
+	Gender    string
+	Fullname  string
+	Email     string
+	Phone     string
+	Role      string
+	CreatedAt string
+	UpdatedAt string
+
+	seq          int64 // creation order
+	passwordHash []byte
+}
+
+// accounts is the in-memory store of one service. Accounts sign in with their email as login.
+type accounts struct {
+	role string // of accounts created through the service
+
+	mu      sync.Mutex
+	seq     int64
+	byID    map[string]*account
+	byLogin map[string]*account
+	otps    map[string]string // pending registrations by email
+}
+
+func newAccounts(role string) *accounts {
+	return &accounts{
+		role:    role,
+		byID:    make(map[string]*account),
+		byLogin: make(map[string]*account),
+		otps:    make(map[string]string),
+	}
+}
+
+func (s *accounts) create(a account, password string) (account, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return account{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a.Login == "" {
+		a.Login = a.Email
+	}
+	a.Login = normalizeLogin(a.Login)
+	if a.Login == "" {
+		return account{}, status.Error(codes.InvalidArgument, "email is required")
+	}
+	if _, ok := s.byLogin[a.Login]; ok {
+		return account{}, status.Errorf(codes.AlreadyExists, "%s already exists", a.Login)
+	}
+
+	if a.Role == "" {
+		a.Role = s.role
+	}
+	s.seq++
+	a.seq = s.seq
+	a.ID = requestid.New()
+	a.CreatedAt = now()
+	a.UpdatedAt = a.CreatedAt
+	a.passwordHash = hash
+
+	s.byID[a.ID] = &a
+	s.byLogin[a.Login] = &a
+	return a, nil
+}
+
+func (s *accounts) get(id string) (account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.byID[id]
+	if !ok {
+		return account{}, status.Errorf(codes.NotFound, "%s not found", id)
+	}
+	return *a, nil
+}
+
+func (s *accounts) list(search string, page, limit int64) ([]account, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	search = strings.ToLower(search)
+	var found []account
+	for _, a := range s.byID {
+		if search == "" ||
+			strings.Contains(strings.ToLower(a.Fullname), search) ||
+			strings.Contains(a.Login, search) ||
+			strings.Contains(a.Phone, search) {
+			found = append(found, *a)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].seq < found[j].seq })
+
+	return paginate(found, page, limit), int64(len(found))
+}
+
+func (s *accounts) update(id string, apply func(a *account)) (account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.byID[id]
+	if !ok {
+		return account{}, status.Errorf(codes.NotFound, "%s not found", id)
+	}
+	apply(a)
+	a.UpdatedAt = now()
+	return *a, nil
+}
+
+func (s *accounts) delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.byID[id]
+	if !ok {
+		return status.Errorf(codes.NotFound, "%s not found", id)
+	}
+	delete(s.byID, id)
+	delete(s.byLogin, a.Login)
+	return nil
+}
+
+func (s *accounts) login(login, password string) (account, error) {
+	s.mu.Lock()
+	a, ok := s.byLogin[normalizeLogin(login)]
+	s.mu.Unlock()
+
+	if !ok || bcrypt.CompareHashAndPassword(a.passwordHash, []byte(password)) != nil {
+		return account{}, status.Error(codes.Unauthenticated, "wrong login or password")
+	}
+	return *a, nil
+}
+
+func (s *accounts) changePassword(login, oldPassword, newPassword string) error {
+	a, err := s.login(login, oldPassword)
+	if err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.MinCost)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	_, err = s.update(a.ID, func(a *account) { a.passwordHash = hash })
+	return err
+}
+
+// register starts a registration, it is confirmed with OTP.
+func (s *accounts) register(email string) error {
+	email = normalizeLogin(email)
+	if email == "" {
+		return status.Error(codes.InvalidArgument, "mail is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byLogin[email]; ok {
+		return status.Errorf(codes.AlreadyExists, "%s already exists", email)
+	}
+	s.otps[email] = OTP
+	return nil
+}
+
+func (s *accounts) confirm(email, otp string, a account, password string) (account, error) {
+	email = normalizeLogin(email)
+
+	s.mu.Lock()
+	want, ok := s.otps[email]
+	if ok && want == otp {
+		delete(s.otps, email)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return account{}, status.Errorf(codes.FailedPrecondition, "no pending registration for %s", email)
+	}
+	if want != otp {
+		return account{}, status.Error(codes.InvalidArgument, "wrong otp")
+	}
+
+	a.Email = email
+	a.Login = email
+	return s.create(a, password)
+}
+
+func paginate[T any](items []T, page, limit int64) []T {
+	if limit <= 0 {
+		return items
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	from := (page - 1) * limit
+	if from >= int64(len(items)) {
+		return nil
+	}
+	to := from + limit
+	if to > int64(len(items)) {
+		to = int64(len(items))
+	}
+	return items[from:to]
+}
+
+func normalizeLogin(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,132 @@
+package devbackend
+
+import (
+	"api_gateway/genproto/admin_service"
+	"api_gateway/pkg/logger"
+	"context"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type adminServer struct {
+	admin_service.UnimplementedAdminServiceServer
+	accounts *accounts
+	log      logger.Logger
+}
+
+func (s *adminServer) Create(_ context.Context, in *admin_service.CreateAdmin) (*admin_service.GetAdmin, error) {
+	a, err := s.accounts.create(account{
+		Birthday: in.Birthday,
+		Gender:   in.Gender,
+		Fullname: in.Fullname,
+		Email:    in.Email,
+		Phone:    in.Phone,
+	}, in.UserPassword)
+	if err != nil {
+		return nil, err
+	}
+	return toAdmin(a), nil
+}
+
+func (s *adminServer) GetByID(_ context.Context, in *admin_service.AdminPrimaryKey) (*admin_service.GetAdmin, error) {
+	a, err := s.accounts.get(in.Id)
+	if err != nil {
+		return nil, err
+	}
+	return toAdmin(a), nil
+}
+
+func (s *adminServer) GetList(_ context.Context, in *admin_service.GetListAdminRequest) (*admin_service.GetListAdminResponse, error) {
+	list, count := s.accounts.list(in.Search, in.Offset, in.Limit)
+
+	resp := &admin_service.GetListAdminResponse{Count: count}
+	for _, a := range list {
+		resp.Admins = append(resp.Admins, toAdmin(a))
+	}
+	return resp, nil
+}
+
+func (s *adminServer) Update(_ context.Context, in *admin_service.UpdateAdmin) (*admin_service.GetAdmin, error) {
+	a, err := s.accounts.update(in.Id, func(a *account) {
+		a.Birthday = in.Birthday
+		a.Gender = in.Gender
+		a.Fullname = in.Fullname
+		a.Email = in.Email
+		a.Phone = in.Phone
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toAdmin(a), nil
+}
+
+func (s *adminServer) Delete(_ context.Context, in *admin_service.AdminPrimaryKey) (*empty.Empty, error) {
+	return &empty.Empty{}, s.accounts.delete(in.Id)
+}
+
+func (s *adminServer) Login(_ context.Context, in *admin_service.AdminLoginRequest) (*admin_service.AdminLoginResponse, error) {
+	a, err := s.accounts.login(in.UserLogin, in.UserPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	access, refresh, err := issueTokens(a)
+	if err != nil {
+		return nil, err
+	}
+	return &admin_service.AdminLoginResponse{AccessToken: access, RefreshToken: refresh}, nil
+}
+
+func (s *adminServer) Register(_ context.Context, in *admin_service.AdminRegisterRequest) (*empty.Empty, error) {
+	if err := s.accounts.register(in.Mail); err != nil {
+		return nil, err
+	}
+	s.log.Info("dev backend registration otp", logger.String("mail", in.Mail), logger.String("otp", OTP))
+	return &empty.Empty{}, nil
+}
+
+func (s *adminServer) RegisterConfirm(_ context.Context, in *admin_service.AdminRegisterConfRequest) (*admin_service.AdminLoginResponse, error) {
+	if len(in.Admin) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "admin is required")
+	}
+	u := in.Admin[0]
+
+	a, err := s.accounts.confirm(in.Mail, in.Otp, account{
+		Birthday: u.Birthday,
+		Gender:   u.Gender,
+		Fullname: u.Fullname,
+		Phone:    u.Phone,
+	}, u.UserPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	access, refresh, err := issueTokens(a)
+	if err != nil {
+		return nil, err
+	}
+	return &admin_service.AdminLoginResponse{AccessToken: access, RefreshToken: refresh}, nil
+}
+
+func (s *adminServer) ChangePassword(_ context.Context, in *admin_service.AdminChangePassword) (*admin_service.AdminChangePasswordResp, error) {
+	if err := s.accounts.changePassword(in.UserLogin, in.OldPassword, in.NewPassword); err != nil {
+		return nil, err
+	}
+	return &admin_service.AdminChangePasswordResp{Comment: "password changed"}, nil
+}
+
+func toAdmin(a account) *admin_service.GetAdmin {
+	return &admin_service.GetAdmin{
+		Id:        a.ID,
+		UserLogin: a.Login,
+		Birthday:  a.Birthday,
+		Gender:    a.Gender,
+		Fullname:  a.Fullname,
+		Email:     a.Email,
+		Phone:     a.Phone,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+	}
+}
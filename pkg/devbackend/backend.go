@@ -0,0 +1,98 @@
+// Package devbackend runs in-memory implementations of the user, admin and
+// task services inside the gateway process, so the whole API can be used in
+// local development without the real services. Data is lost on exit.
+package devbackend
+
+import (
+	"api_gateway/config"
+	"api_gateway/genproto/admin_service"
+	"api_gateway/genproto/task_service"
+	"api_gateway/genproto/user_service"
+	"api_gateway/pkg/logger"
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	// OTP is the one-time password that confirms every registration.
+	OTP = "123456"
+
+	// SuperadminLogin and SuperadminPassword sign in the seeded superadmin.
+	SuperadminLogin    = "superadmin@gmail.com"
+	SuperadminPassword = "Superadmin1!"
+
+	bufSize = 1 << 20
+)
+
+// Backends serves the in-memory services over an in-process bufconn listener.
+type Backends struct {
+	server   *grpc.Server
+	listener *bufconn.Listener
+}
+
+// Start serves the in-memory services until Stop is called.
+func Start(log logger.Logger) (*Backends, error) {
+	admins := newAccounts(config.ADMIN_ROLE)
+	if _, err := admins.create(account{
+		Login:    SuperadminLogin,
+		Email:    SuperadminLogin,
+		Fullname: "Superadmin",
+		Role:     config.SUPERADMIN_ROLE,
+	}, SuperadminPassword); err != nil {
+		return nil, err
+	}
+
+	b := &Backends{
+		server:   grpc.NewServer(),
+		listener: bufconn.Listen(bufSize),
+	}
+
+	user_service.RegisterUserServiceServer(b.server, &userServer{accounts: newAccounts(config.USER_ROLE), log: log})
+	admin_service.RegisterAdminServiceServer(b.server, &adminServer{accounts: admins, log: log})
+	task_service.RegisterTaskServiceServer(b.server, &taskServer{tasks: newTasks()})
+	healthpb.RegisterHealthServer(b.server, health.NewServer())
+
+	go func() {
+		if err := b.server.Serve(b.listener); err != nil {
+			log.Error("dev backends stopped", logger.Error(err))
+		}
+	}()
+
+	log.Warn("serving in-memory dev backends, data is lost on exit",
+		logger.String("superadmin_login", SuperadminLogin),
+		logger.String("superadmin_password", SuperadminPassword),
+		logger.String("otp", OTP))
+	return b, nil
+}
+
+// Configure points the service connections of cfg at the dev backends.
+// Their addresses are only names, DialOptions connects them in-process.
+func (b *Backends) Configure(cfg *config.Config) {
+	cfg.UserServiceAddrs = []string{"dev-user-service:0"}
+	cfg.TaskServiceAddrs = []string{"dev-task-service:0"}
+
+	lb := config.LBConfig{Policy: config.LBPolicyPickFirst}
+	cfg.UserServiceLB, cfg.TaskServiceLB = lb, lb
+
+	plaintext := config.TLSConfig{Mode: config.TLSModePlaintext}
+	cfg.UserServiceTLS, cfg.TaskServiceTLS = plaintext, plaintext
+}
+
+// DialOptions connect the gRPC clients to the dev backends.
+func (b *Backends) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return b.listener.DialContext(ctx)
+		}),
+	}
+}
+
+// Stop stops serving and drops all data.
+func (b *Backends) Stop() {
+	b.server.Stop()
+}
@@ -0,0 +1,231 @@
+package devbackend
+
+import (
+	"api_gateway/genproto/task_service"
+	"api_gateway/pkg/requestid"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultTaskStatus is the status of tasks created without one.
+const defaultTaskStatus = "new"
+
+type task struct {
+	ID          string
+	ExternalID  string
+	UserID      string
+	Title       string
+	Status      string
+	Description string
+	Deadline    string
+	CreatedAt   string
+	UpdatedAt   string
+
+	seq int64 // creation order, also numbers the external id
+}
+
+// tasks is the in-memory store of the task service.
+type tasks struct {
+	mu   sync.Mutex
+	seq  int64
+	byID map[string]*task
+}
+
+func newTasks() *tasks {
+	return &tasks{byID: make(map[string]*task)}
+}
+
+func (s *tasks) create(t task) task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.Status == "" {
+		t.Status = defaultTaskStatus
+	}
+	s.seq++
+	t.seq = s.seq
+	t.ID = requestid.New()
+	t.ExternalID = fmt.Sprintf("T-%06d", t.seq)
+	t.CreatedAt = now()
+	t.UpdatedAt = t.CreatedAt
+
+	s.byID[t.ID] = &t
+	return t
+}
+
+func (s *tasks) get(id string) (task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.byID[id]
+	if !ok {
+		return task{}, status.Errorf(codes.NotFound, "task %s not found", id)
+	}
+	return *t, nil
+}
+
+func (s *tasks) getByExternalID(externalID string) (task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.byID {
+		if t.ExternalID == externalID {
+			return *t, nil
+		}
+	}
+	return task{}, status.Errorf(codes.NotFound, "task %s not found", externalID)
+}
+
+func (s *tasks) update(id string, apply func(t *task)) (task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.byID[id]
+	if !ok {
+		return task{}, status.Errorf(codes.NotFound, "task %s not found", id)
+	}
+	apply(t)
+	t.UpdatedAt = now()
+	return *t, nil
+}
+
+func (s *tasks) delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byID[id]; !ok {
+		return status.Errorf(codes.NotFound, "task %s not found", id)
+	}
+	delete(s.byID, id)
+	return nil
+}
+
+// list filters by owner, title and the creation date range, dates compare as RFC 3339 strings.
+func (s *tasks) list(in *task_service.GetListTaskRequest) ([]task, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	search := strings.ToLower(in.Search)
+	var found []task
+	for _, t := range s.byID {
+		switch {
+		case in.OwnerId != "" && t.UserID != in.OwnerId:
+		case search != "" && !strings.Contains(strings.ToLower(t.Title), search):
+		case in.FromDate != "" && t.CreatedAt < in.FromDate:
+		case in.ToDate != "" && t.CreatedAt > in.ToDate:
+		default:
+			found = append(found, *t)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].seq < found[j].seq })
+
+	return paginate(found, in.Offset, in.Limit), int64(len(found))
+}
+
+type taskServer struct {
+	task_service.UnimplementedTaskServiceServer
+	tasks *tasks
+}
+
+func (s *taskServer) Create(_ context.Context, in *task_service.CreateTask) (*task_service.GetTask, error) {
+	if in.Title == "" {
+		return nil, status.Error(codes.InvalidArgument, "title is required")
+	}
+
+	t := s.tasks.create(task{
+		UserID:      in.UserId,
+		Title:       in.Title,
+		Status:      in.TaskStatus,
+		Description: in.TaskDescription,
+		Deadline:    in.Deadline,
+	})
+	return toTask(t), nil
+}
+
+func (s *taskServer) GetByID(_ context.Context, in *task_service.TaskPrimaryKey) (*task_service.GetTask, error) {
+	t, err := s.tasks.get(in.Id)
+	if err != nil {
+		return nil, err
+	}
+	return toTask(t), nil
+}
+
+func (s *taskServer) GetByExternalId(_ context.Context, in *task_service.TaskPrimaryKey) (*task_service.GetTask, error) {
+	t, err := s.tasks.getByExternalID(in.Id)
+	if err != nil {
+		return nil, err
+	}
+	return toTask(t), nil
+}
+
+// Update changes title and deadline, the description of UpdateTask is a number
+// in the task service contract and is ignored here.
+func (s *taskServer) Update(_ context.Context, in *task_service.UpdateTask) (*task_service.GetTask, error) {
+	t, err := s.tasks.update(in.Id, func(t *task) {
+		if in.Title != "" {
+			t.Title = in.Title
+		}
+		t.Deadline = in.Deadline
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toTask(t), nil
+}
+
+func (s *taskServer) ChangeStatus(_ context.Context, in *task_service.TaskChangeStatus) (*task_service.TaskChangeStatusResp, error) {
+	if in.NewStatus == "" {
+		return nil, status.Error(codes.InvalidArgument, "new_status is required")
+	}
+
+	_, err := s.tasks.update(in.TaskId, func(t *task) { t.Status = in.NewStatus })
+	if err != nil {
+		return nil, err
+	}
+	return &task_service.TaskChangeStatusResp{Comment: "status changed"}, nil
+}
+
+func (s *taskServer) Delete(_ context.Context, in *task_service.TaskPrimaryKey) (*empty.Empty, error) {
+	return &empty.Empty{}, s.tasks.delete(in.Id)
+}
+
+func (s *taskServer) GetList(_ context.Context, in *task_service.GetListTaskRequest) (*task_service.GetListTaskResponse, error) {
+	list, count := s.tasks.list(in)
+
+	resp := &task_service.GetListTaskResponse{Count: count}
+	for _, t := range list {
+		resp.Tasks = append(resp.Tasks, &task_service.GetListTask{
+			Id:              t.ID,
+			ExternalId:      t.ExternalID,
+			UserId:          t.UserID,
+			Title:           t.Title,
+			TaskStatus:      t.Status,
+			TaskDescription: t.Description,
+			Deadline:        t.Deadline,
+			CreatedAt:       t.CreatedAt,
+			UpdatedAt:       t.UpdatedAt,
+		})
+	}
+	return resp, nil
+}
+
+func toTask(t task) *task_service.GetTask {
+	return &task_service.GetTask{
+		Id:              t.ID,
+		ExternalId:      t.ExternalID,
+		UserId:          t.UserID,
+		Title:           t.Title,
+		TaskStatus:      t.Status,
+		TaskDescription: t.Description,
+		Deadline:        t.Deadline,
+		CreatedAt:       t.CreatedAt,
+		UpdatedAt:       t.UpdatedAt,
+	}
+}
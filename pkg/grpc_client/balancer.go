@@ -0,0 +1,63 @@
+package grpc_client
+
+import (
+	"api_gateway/config"
+	"encoding/json"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/leastrequest"
+	_ "google.golang.org/grpc/health" // client side health checking of balanced replicas
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// dialTarget returns the target of a service and the dial options that
+// resolve and balance its replicas. A static list of addresses is served by
+// a manual resolver, otherwise every address host resolves to in DNS is used.
+func dialTarget(service, host, port string, addrs []string, lb config.LBConfig) (string, []grpc.DialOption) {
+	opts := []grpc.DialOption{
+		// balancing is configured by the gateway, not by DNS TXT records
+		grpc.WithDisableServiceConfig(),
+		grpc.WithDefaultServiceConfig(serviceConfig(lb)),
+	}
+
+	if len(addrs) == 0 {
+		return "dns:///" + net.JoinHostPort(host, port), opts
+	}
+
+	state := resolver.State{}
+	for _, addr := range addrs {
+		state.Addresses = append(state.Addresses, resolver.Address{Addr: addr})
+	}
+
+	r := manual.NewBuilderWithScheme("gateway-" + strings.ReplaceAll(service, "_", "-"))
+	r.InitialState(state)
+
+	return r.Scheme() + ":///" + service, append(opts, grpc.WithResolvers(r))
+}
+
+// serviceConfig returns the gRPC service config JSON of a load balancing configuration.
+func serviceConfig(lb config.LBConfig) string {
+	policy := map[string]interface{}{}
+	switch lb.Policy {
+	case config.LBPolicyLeastRequest:
+		policy[leastrequest.Name] = map[string]interface{}{"choiceCount": 2}
+	case config.LBPolicyPickFirst:
+		policy[config.LBPolicyPickFirst] = map[string]interface{}{}
+	default:
+		policy[config.LBPolicyRoundRobin] = map[string]interface{}{}
+	}
+
+	sc := map[string]interface{}{
+		"loadBalancingConfig": []interface{}{policy},
+	}
+	if lb.HealthCheck {
+		// an empty service name checks the overall health of the server
+		sc["healthCheckConfig"] = map[string]interface{}{"serviceName": ""}
+	}
+
+	data, _ := json.Marshal(sc)
+	return string(data)
+}
@@ -0,0 +1,191 @@
+package grpc_client
+
+import (
+	"api_gateway/config"
+	"api_gateway/genproto/task_service"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestServiceConfig(t *testing.T) {
+	for _, tc := range []struct {
+		lb   config.LBConfig
+		want string
+	}{
+		{config.LBConfig{Policy: config.LBPolicyPickFirst},
+			`{"loadBalancingConfig":[{"pick_first":{}}]}`},
+		{config.LBConfig{Policy: config.LBPolicyRoundRobin, HealthCheck: true},
+			`{"loadBalancingConfig":[{"round_robin":{}}],"healthCheckConfig":{"serviceName":""}}`},
+		{config.LBConfig{Policy: config.LBPolicyLeastRequest},
+			`{"loadBalancingConfig":[{"least_request_experimental":{"choiceCount":2}}]}`},
+		{config.LBConfig{},
+			`{"loadBalancingConfig":[{"round_robin":{}}]}`},
+	} {
+		var got, want interface{}
+		if err := json.Unmarshal([]byte(serviceConfig(tc.lb)), &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: service config = %s, want %s", tc.lb, serviceConfig(tc.lb), tc.want)
+		}
+	}
+}
+
+// replica is a task service replica counting the task lists it serves.
+type replica struct {
+	task_service.UnimplementedTaskServiceServer
+	listener *bufconn.Listener
+	health   *health.Server
+	calls    atomic.Int32
+}
+
+func (r *replica) GetList(context.Context, *task_service.GetListTaskRequest) (*task_service.GetListTaskResponse, error) {
+	r.calls.Add(1)
+	return &task_service.GetListTaskResponse{}, nil
+}
+
+// startReplicas serves n replicas on bufconn, addressed replica-0, replica-1, ...
+func startReplicas(t *testing.T, n int) ([]*replica, []string, grpc.DialOption) {
+	t.Helper()
+
+	var (
+		replicas []*replica
+		addrs    []string
+		byAddr   = make(map[string]*bufconn.Listener, n)
+	)
+	for i := 0; i < n; i++ {
+		r := &replica{listener: bufconn.Listen(1 << 16), health: health.NewServer()}
+		server := grpc.NewServer()
+		task_service.RegisterTaskServiceServer(server, r)
+		healthpb.RegisterHealthServer(server, r.health)
+		go server.Serve(r.listener)
+		t.Cleanup(server.Stop)
+
+		addr := fmt.Sprintf("replica-%d:50051", i)
+		replicas = append(replicas, r)
+		addrs = append(addrs, addr)
+		byAddr[addr] = r.listener
+	}
+
+	dialer := grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return byAddr[addr].DialContext(ctx)
+	})
+	return replicas, addrs, dialer
+}
+
+func dialReplicas(t *testing.T, lb config.LBConfig, addrs []string, dialer grpc.DialOption) task_service.TaskServiceClient {
+	t.Helper()
+
+	target, opts := dialTarget(TaskServiceName, "", "", addrs, lb)
+	conn, err := grpc.NewClient(target, append(opts, dialer, grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return task_service.NewTaskServiceClient(conn)
+}
+
+// callsOf makes n task lists and returns the calls each replica served.
+func callsOf(t *testing.T, client task_service.TaskServiceClient, replicas []*replica, n int) []int32 {
+	t.Helper()
+
+	for _, r := range replicas {
+		r.calls.Store(0)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := client.GetList(context.Background(), &task_service.GetListTaskRequest{}); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+
+	calls := make([]int32, len(replicas))
+	for i, r := range replicas {
+		calls[i] = r.calls.Load()
+	}
+	return calls
+}
+
+// waitForCalls calls until every replica with want true has served a call, as
+// the balancers only pick replicas once their connection is ready.
+func waitForCalls(t *testing.T, client task_service.TaskServiceClient, replicas []*replica, want ...bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		calls := callsOf(t, client, replicas, len(replicas))
+		ready := true
+		for i, w := range want {
+			if w && calls[i] == 0 {
+				ready = false
+			}
+		}
+		if ready {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("calls = %v, want every replica of %v used", calls, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBalancingPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy string
+		spread bool
+	}{
+		{config.LBPolicyPickFirst, false},
+		{config.LBPolicyRoundRobin, true},
+		{config.LBPolicyLeastRequest, true},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			replicas, addrs, dialer := startReplicas(t, 3)
+			client := dialReplicas(t, config.LBConfig{Policy: tc.policy}, addrs, dialer)
+
+			if !tc.spread {
+				if calls := callsOf(t, client, replicas, 30); calls[0] != 30 {
+					t.Fatalf("calls = %v, want all on the first replica", calls)
+				}
+				return
+			}
+
+			waitForCalls(t, client, replicas, true, true, true)
+			calls := callsOf(t, client, replicas, 30)
+			for i, n := range calls {
+				if n == 0 {
+					t.Fatalf("calls = %v, replica %d unused", calls, i)
+				}
+			}
+		})
+	}
+}
+
+func TestBalancingSkipsUnhealthyReplicas(t *testing.T) {
+	replicas, addrs, dialer := startReplicas(t, 3)
+	replicas[1].health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	client := dialReplicas(t, config.LBConfig{Policy: config.LBPolicyRoundRobin, HealthCheck: true}, addrs, dialer)
+	waitForCalls(t, client, replicas, true, false, true)
+	if calls := callsOf(t, client, replicas, 30); calls[1] != 0 {
+		t.Fatalf("calls = %v, want none on the unhealthy replica", calls)
+	}
+
+	// back in rotation once it serves again
+	replicas[1].health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	waitForCalls(t, client, replicas, true, true, true)
+}
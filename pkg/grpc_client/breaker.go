@@ -0,0 +1,221 @@
+package grpc_client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Circuit breaker states.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// BreakerSettings configures the circuit breaker of a downstream service.
+type BreakerSettings struct {
+	// FailureThreshold consecutive failures open the breaker.
+	FailureThreshold int
+	// OpenTimeout is how long an open breaker fails fast before probing the service.
+	OpenTimeout time.Duration
+	// HalfOpenProbes calls are let through while probing, all of them
+	// must succeed to close the breaker again.
+	HalfOpenProbes int
+}
+
+// BreakerStatus is a snapshot of a circuit breaker.
+type BreakerStatus struct {
+	Service   string    `json:"service"`
+	State     string    `json:"state"`
+	Failures  int       `json:"failures"`
+	OpenedAt  time.Time `json:"opened_at"`
+	OpenUntil time.Time `json:"open_until"`
+}
+
+// CircuitOpenError is returned without calling the service while its breaker is open.
+// It converts to a codes.Unavailable status.
+type CircuitOpenError struct {
+	Service string
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s is unavailable: circuit breaker open", e.Service)
+}
+
+// GRPCStatus lets status.FromError and status.Code treat the error as codes.Unavailable.
+func (e *CircuitOpenError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
+type breaker struct {
+	service  string
+	settings BreakerSettings
+
+	mu         sync.Mutex
+	state      string
+	generation uint64 // bumped on every state change, results of older calls are ignored
+	failures   int
+	openedAt   time.Time
+	probes     int
+	successes  int
+}
+
+func newBreaker(service string, settings BreakerSettings) *breaker {
+	return &breaker{
+		service:  service,
+		settings: settings,
+		state:    BreakerClosed,
+	}
+}
+
+// allow admits a call, it returns the generation to report the result with.
+func (b *breaker) allow(now time.Time) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		openUntil := b.openedAt.Add(b.settings.OpenTimeout)
+		if now.Before(openUntil) {
+			return 0, &CircuitOpenError{Service: b.service, RetryAt: openUntil}
+		}
+		b.setState(BreakerHalfOpen, now)
+	}
+
+	if b.state == BreakerHalfOpen {
+		if b.probes >= b.settings.HalfOpenProbes {
+			return 0, &CircuitOpenError{Service: b.service, RetryAt: now.Add(time.Second)}
+		}
+		b.probes++
+	}
+
+	return b.generation, nil
+}
+
+// done records the result of a call admitted in generation.
+func (b *breaker) done(generation uint64, failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case BreakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.settings.FailureThreshold {
+			b.setState(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		if failed {
+			b.setState(BreakerOpen, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.settings.HalfOpenProbes {
+			b.setState(BreakerClosed, now)
+		}
+	}
+}
+
+// release gives back a probe slot of a call whose result says nothing about the service.
+func (b *breaker) release(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation == b.generation && b.state == BreakerHalfOpen {
+		b.probes--
+	}
+}
+
+func (b *breaker) setState(state string, now time.Time) {
+	b.state = state
+	b.generation++
+	b.probes = 0
+	b.successes = 0
+
+	switch state {
+	case BreakerOpen:
+		b.openedAt = now
+	case BreakerClosed:
+		b.failures = 0
+	}
+}
+
+func (b *breaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := BreakerStatus{
+		Service:  b.service,
+		State:    b.state,
+		Failures: b.failures,
+	}
+	if b.state != BreakerClosed {
+		s.OpenedAt = b.openedAt
+		s.OpenUntil = b.openedAt.Add(b.settings.OpenTimeout)
+	}
+	return s
+}
+
+// breakers holds one circuit breaker per downstream service.
+type breakers map[string]*breaker
+
+func newBreakers(settings BreakerSettings, services ...string) breakers {
+	bs := make(breakers, len(services))
+	for _, service := range services {
+		bs[service] = newBreaker(service, settings)
+	}
+	return bs
+}
+
+// unaryInterceptor fails fast while the breaker of the called service is open.
+// Unavailable and DeadlineExceeded count as failures, any other result shows
+// the service is up.
+func (bs breakers) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		b, ok := bs[serviceOf(method)]
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		generation, err := b.allow(time.Now())
+		if err != nil {
+			return err
+		}
+
+		err = invoker(ctx, method, req, reply, cc, opts...)
+
+		switch status.Code(err) {
+		case codes.Canceled:
+			// the caller went away, the service may be fine
+			b.release(generation)
+		case codes.Unavailable, codes.DeadlineExceeded:
+			b.done(generation, true, time.Now())
+		default:
+			b.done(generation, false, time.Now())
+		}
+		return err
+	}
+}
+
+func (bs breakers) statuses() []BreakerStatus {
+	list := make([]BreakerStatus, 0, len(bs))
+	for _, b := range bs {
+		list = append(list, b.status())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Service < list[j].Service })
+	return list
+}
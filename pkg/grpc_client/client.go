@@ -5,61 +5,155 @@ import (
 	"api_gateway/genproto/admin_service"
 	"api_gateway/genproto/task_service"
 	"api_gateway/genproto/user_service"
+	"api_gateway/pkg/logger"
 
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
+// Names of the downstream services in logs, errors and configuration.
+const (
+	UserServiceName  = "user_service"
+	AdminServiceName = "admin_service"
+	TaskServiceName  = "task_service"
+)
+
+// serviceNames maps gRPC service names to the names of the downstream services.
+var serviceNames = map[string]string{
+	user_service.UserService_ServiceDesc.ServiceName:   UserServiceName,
+	admin_service.AdminService_ServiceDesc.ServiceName: AdminServiceName,
+	task_service.TaskService_ServiceDesc.ServiceName:   TaskServiceName,
+}
+
+// GrpcClientI is the gateway's access to the downstream services.
+// Handlers depend on it, their unit tests substitute the fakes of package grpc_client/fake.
 type GrpcClientI interface {
-	User() user_service.UserServiceClient
-	Admin() admin_service.AdminServiceClient
-	Task() task_service.TaskServiceClient
+	UserService() user_service.UserServiceClient
+	AdminService() admin_service.AdminServiceClient
+	TaskService() task_service.TaskServiceClient
+
+	// Breakers returns the state of the circuit breaker of every downstream service.
+	Breakers() []BreakerStatus
+	// CheckHealth probes the connection to every downstream service.
+	CheckHealth(ctx context.Context) map[string]Health
+	// Close closes the connections to all downstream services.
+	Close() error
 }
 
 type GrpcClient struct {
-	cfg         config.Config
-	connections map[string]interface{}
+	cfg          config.Config
+	userService  user_service.UserServiceClient
+	adminService admin_service.AdminServiceClient
+	taskService  task_service.TaskServiceClient
+	conns        map[string]*grpc.ClientConn // by service, admin_service shares the user_service connection
+	breakers     breakers
 }
 
-func New(cfg config.Config) (*GrpcClient, error) {
-	connUser, err := grpc.NewClient(
-		fmt.Sprintf("%s:%s", cfg.UserServiceHost, cfg.UserServicePort),
-		grpc.WithTransportCredentials(insecure.NewCredentials()))
+var _ GrpcClientI = (*GrpcClient)(nil)
+
+// New connects to the downstream services. opts are added to the dial options
+// of every connection, e.g. a context dialer for in-process services.
+func New(cfg config.Config, log logger.Logger, opts ...grpc.DialOption) (*GrpcClient, error) {
+	breakers := newBreakers(BreakerSettings{
+		FailureThreshold: cfg.BreakerFailureThreshold,
+		OpenTimeout:      cfg.BreakerOpenTimeout,
+		HalfOpenProbes:   cfg.BreakerHalfOpenProbes,
+	}, UserServiceName, AdminServiceName, TaskServiceName)
+
+	// the span, the metrics and the deadline cover all retries, retries wrap
+	// the breaker, every attempt is counted and an open breaker stops them
+	interceptors := grpc.WithChainUnaryInterceptor(
+		requestIDInterceptor(),
+		tracingInterceptor(),
+		metricsInterceptor(),
+		timeoutInterceptor(Timeouts{
+			Default: cfg.GrpcTimeout,
+			ByName:  cfg.GrpcTimeouts,
+		}),
+		retryInterceptor(RetrySettings{
+			MaxAttempts:    cfg.GrpcRetryMaxAttempts,
+			InitialBackoff: cfg.GrpcRetryInitialBackoff,
+			MaxBackoff:     cfg.GrpcRetryMaxBackoff,
+		}, log),
+		breakers.unaryInterceptor(),
+	)
+
+	userCreds, err := transportCredentials(cfg.UserServiceTLS)
+	if err != nil {
+		return nil, fmt.Errorf("user service tls: %w", err)
+	}
+	taskCreds, err := transportCredentials(cfg.TaskServiceTLS)
+	if err != nil {
+		return nil, fmt.Errorf("task service tls: %w", err)
+	}
+
+	userTarget, userOpts := dialTarget(UserServiceName,
+		cfg.UserServiceHost, cfg.UserServicePort, cfg.UserServiceAddrs, cfg.UserServiceLB)
+	connUser, err := grpc.NewClient(userTarget,
+		append(append(userOpts, opts...), grpc.WithTransportCredentials(userCreds), interceptors)...)
 
 	if err != nil {
-		return nil, fmt.Errorf("user service dial host: %v port:%v err: %v",
-			cfg.UserServiceHost, cfg.UserServicePort, err)
+		return nil, fmt.Errorf("user service dial %v: %v", userTarget, err)
 	}
 
-	connTask, err := grpc.NewClient(
-		fmt.Sprintf("%s:%s", cfg.TaskServiceHost, cfg.TaskServicePort),
-		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	taskTarget, taskOpts := dialTarget(TaskServiceName,
+		cfg.TaskServiceHost, cfg.TaskServicePort, cfg.TaskServiceAddrs, cfg.TaskServiceLB)
+	connTask, err := grpc.NewClient(taskTarget,
+		append(append(taskOpts, opts...), grpc.WithTransportCredentials(taskCreds), interceptors)...)
 
 	if err != nil {
-		return nil, fmt.Errorf("user service dial host: %v port:%v err: %v",
-			cfg.TaskServiceHost, cfg.TaskServicePort, err)
+		connUser.Close()
+		return nil, fmt.Errorf("task service dial %v: %v", taskTarget, err)
 	}
 
 	return &GrpcClient{
-		cfg: cfg,
-		connections: map[string]interface{}{
-			"user_service":  user_service.NewUserServiceClient(connUser),
-			"admin_service": admin_service.NewAdminServiceClient(connUser),
-			"task_service":  task_service.NewTaskServiceClient(connTask),
+		cfg:          cfg,
+		userService:  user_service.NewUserServiceClient(connUser),
+		adminService: admin_service.NewAdminServiceClient(connUser),
+		taskService:  task_service.NewTaskServiceClient(connTask),
+		conns: map[string]*grpc.ClientConn{
+			UserServiceName: connUser,
+			TaskServiceName: connTask,
 		},
+		breakers: breakers,
 	}, nil
 }
 
 func (g *GrpcClient) UserService() user_service.UserServiceClient {
-	return g.connections["user_service"].(user_service.UserServiceClient)
+	return g.userService
 }
 
 func (g *GrpcClient) AdminService() admin_service.AdminServiceClient {
-	return g.connections["admin_service"].(admin_service.AdminServiceClient)
+	return g.adminService
 }
 
 func (g *GrpcClient) TaskService() task_service.TaskServiceClient {
-	return g.connections["task_service"].(task_service.TaskServiceClient)
+	return g.taskService
+}
+
+// Close closes the connections to all downstream services.
+func (g *GrpcClient) Close() error {
+	var errs []error
+	for service, conn := range g.conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", service, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Breakers returns the state of the circuit breaker of every downstream service.
+func (g *GrpcClient) Breakers() []BreakerStatus {
+	return g.breakers.statuses()
+}
+
+// serviceOf returns the downstream service of a full method name,
+// e.g. "task_service" for "/task_service_go.TaskService/GetByID".
+func serviceOf(method string) string {
+	service, _, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	return serviceNames[service]
 }
@@ -0,0 +1,99 @@
+package fake
+
+import (
+	"api_gateway/genproto/admin_service"
+	"context"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc"
+)
+
+// AdminService is a admin_service.AdminServiceClient whose methods call the function of the same name.
+// Methods without a function fail with codes.Unimplemented.
+type AdminService struct {
+	Recorder
+
+	CreateFunc          func(ctx context.Context, in *admin_service.CreateAdmin) (*admin_service.GetAdmin, error)
+	GetByIDFunc         func(ctx context.Context, in *admin_service.AdminPrimaryKey) (*admin_service.GetAdmin, error)
+	GetListFunc         func(ctx context.Context, in *admin_service.GetListAdminRequest) (*admin_service.GetListAdminResponse, error)
+	UpdateFunc          func(ctx context.Context, in *admin_service.UpdateAdmin) (*admin_service.GetAdmin, error)
+	DeleteFunc          func(ctx context.Context, in *admin_service.AdminPrimaryKey) (*empty.Empty, error)
+	LoginFunc           func(ctx context.Context, in *admin_service.AdminLoginRequest) (*admin_service.AdminLoginResponse, error)
+	RegisterFunc        func(ctx context.Context, in *admin_service.AdminRegisterRequest) (*empty.Empty, error)
+	RegisterConfirmFunc func(ctx context.Context, in *admin_service.AdminRegisterConfRequest) (*admin_service.AdminLoginResponse, error)
+	ChangePasswordFunc  func(ctx context.Context, in *admin_service.AdminChangePassword) (*admin_service.AdminChangePasswordResp, error)
+}
+
+var _ admin_service.AdminServiceClient = (*AdminService)(nil)
+
+func (f *AdminService) Create(ctx context.Context, in *admin_service.CreateAdmin, _ ...grpc.CallOption) (*admin_service.GetAdmin, error) {
+	f.record("Create", in)
+	if f.CreateFunc == nil {
+		return nil, unimplemented("AdminService", "Create")
+	}
+	return f.CreateFunc(ctx, in)
+}
+
+func (f *AdminService) GetByID(ctx context.Context, in *admin_service.AdminPrimaryKey, _ ...grpc.CallOption) (*admin_service.GetAdmin, error) {
+	f.record("GetByID", in)
+	if f.GetByIDFunc == nil {
+		return nil, unimplemented("AdminService", "GetByID")
+	}
+	return f.GetByIDFunc(ctx, in)
+}
+
+func (f *AdminService) GetList(ctx context.Context, in *admin_service.GetListAdminRequest, _ ...grpc.CallOption) (*admin_service.GetListAdminResponse, error) {
+	f.record("GetList", in)
+	if f.GetListFunc == nil {
+		return nil, unimplemented("AdminService", "GetList")
+	}
+	return f.GetListFunc(ctx, in)
+}
+
+func (f *AdminService) Update(ctx context.Context, in *admin_service.UpdateAdmin, _ ...grpc.CallOption) (*admin_service.GetAdmin, error) {
+	f.record("Update", in)
+	if f.UpdateFunc == nil {
+		return nil, unimplemented("AdminService", "Update")
+	}
+	return f.UpdateFunc(ctx, in)
+}
+
+func (f *AdminService) Delete(ctx context.Context, in *admin_service.AdminPrimaryKey, _ ...grpc.CallOption) (*empty.Empty, error) {
+	f.record("Delete", in)
+	if f.DeleteFunc == nil {
+		return nil, unimplemented("AdminService", "Delete")
+	}
+	return f.DeleteFunc(ctx, in)
+}
+
+func (f *AdminService) Login(ctx context.Context, in *admin_service.AdminLoginRequest, _ ...grpc.CallOption) (*admin_service.AdminLoginResponse, error) {
+	f.record("Login", in)
+	if f.LoginFunc == nil {
+		return nil, unimplemented("AdminService", "Login")
+	}
+	return f.LoginFunc(ctx, in)
+}
+
+func (f *AdminService) Register(ctx context.Context, in *admin_service.AdminRegisterRequest, _ ...grpc.CallOption) (*empty.Empty, error) {
+	f.record("Register", in)
+	if f.RegisterFunc == nil {
+		return nil, unimplemented("AdminService", "Register")
+	}
+	return f.RegisterFunc(ctx, in)
+}
+
+func (f *AdminService) RegisterConfirm(ctx context.Context, in *admin_service.AdminRegisterConfRequest, _ ...grpc.CallOption) (*admin_service.AdminLoginResponse, error) {
+	f.record("RegisterConfirm", in)
+	if f.RegisterConfirmFunc == nil {
+		return nil, unimplemented("AdminService", "RegisterConfirm")
+	}
+	return f.RegisterConfirmFunc(ctx, in)
+}
+
+func (f *AdminService) ChangePassword(ctx context.Context, in *admin_service.AdminChangePassword, _ ...grpc.CallOption) (*admin_service.AdminChangePasswordResp, error) {
+	f.record("ChangePassword", in)
+	if f.ChangePasswordFunc == nil {
+		return nil, unimplemented("AdminService", "ChangePassword")
+	}
+	return f.ChangePasswordFunc(ctx, in)
+}
@@ -0,0 +1,121 @@
+// Package fake provides hand-written fakes of the gRPC clients, so handlers
+// can be tested without the downstream services.
+//
+//	client := fake.New()
+//	client.Task.GetByIDFunc = func(ctx context.Context, in *task_service.TaskPrimaryKey) (*task_service.GetTask, error) {
+//		return &task_service.GetTask{Id: in.Id}, nil
+//	}
+package fake
+
+import (
+	"api_gateway/genproto/admin_service"
+	"api_gateway/genproto/task_service"
+	"api_gateway/genproto/user_service"
+	"api_gateway/pkg/grpc_client"
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Client is a grpc_client.GrpcClientI serving the fake services.
+type Client struct {
+	User  *UserService
+	Admin *AdminService
+	Task  *TaskService
+
+	// BreakerStatuses is returned by Breakers.
+	BreakerStatuses []grpc_client.BreakerStatus
+	// Health is returned by CheckHealth, every service is healthy when nil.
+	Health map[string]grpc_client.Health
+
+	Closed bool
+}
+
+var _ grpc_client.GrpcClientI = (*Client)(nil)
+
+// New returns a Client whose services fail every call until their functions are set.
+func New() *Client {
+	return &Client{
+		User:  &UserService{},
+		Admin: &AdminService{},
+		Task:  &TaskService{},
+	}
+}
+
+func (c *Client) UserService() user_service.UserServiceClient {
+	return c.User
+}
+
+func (c *Client) AdminService() admin_service.AdminServiceClient {
+	return c.Admin
+}
+
+func (c *Client) TaskService() task_service.TaskServiceClient {
+	return c.Task
+}
+
+func (c *Client) Breakers() []grpc_client.BreakerStatus {
+	return c.BreakerStatuses
+}
+
+func (c *Client) CheckHealth(context.Context) map[string]grpc_client.Health {
+	if c.Health != nil {
+		return c.Health
+	}
+	return map[string]grpc_client.Health{
+		grpc_client.UserServiceName: {State: "READY"},
+		grpc_client.TaskServiceName: {State: "READY"},
+	}
+}
+
+func (c *Client) Close() error {
+	c.Closed = true
+	return nil
+}
+
+// Call is a recorded call of a fake service method.
+type Call struct {
+	Method  string
+	Request interface{}
+}
+
+// Recorder records the calls of a fake service.
+type Recorder struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Calls returns the calls made so far, in order.
+func (c *Recorder) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Call(nil), c.calls...)
+}
+
+// Called returns the requests of the calls of method, in order.
+func (c *Recorder) Called(method string) []interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var requests []interface{}
+	for _, call := range c.calls {
+		if call.Method == method {
+			requests = append(requests, call.Request)
+		}
+	}
+	return requests
+}
+
+func (c *Recorder) record(method string, req interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, Call{Method: method, Request: req})
+}
+
+func unimplemented(service, method string) error {
+	return status.Errorf(codes.Unimplemented, "fake %s.%s is not set", service, method)
+}
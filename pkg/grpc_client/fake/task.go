@@ -0,0 +1,81 @@
+package fake
+
+import (
+	"api_gateway/genproto/task_service"
+	"context"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc"
+)
+
+// TaskService is a task_service.TaskServiceClient whose methods call the function of the same name.
+// Methods without a function fail with codes.Unimplemented.
+type TaskService struct {
+	Recorder
+
+	CreateFunc          func(ctx context.Context, in *task_service.CreateTask) (*task_service.GetTask, error)
+	GetByIDFunc         func(ctx context.Context, in *task_service.TaskPrimaryKey) (*task_service.GetTask, error)
+	GetByExternalIdFunc func(ctx context.Context, in *task_service.TaskPrimaryKey) (*task_service.GetTask, error)
+	UpdateFunc          func(ctx context.Context, in *task_service.UpdateTask) (*task_service.GetTask, error)
+	ChangeStatusFunc    func(ctx context.Context, in *task_service.TaskChangeStatus) (*task_service.TaskChangeStatusResp, error)
+	DeleteFunc          func(ctx context.Context, in *task_service.TaskPrimaryKey) (*empty.Empty, error)
+	GetListFunc         func(ctx context.Context, in *task_service.GetListTaskRequest) (*task_service.GetListTaskResponse, error)
+}
+
+var _ task_service.TaskServiceClient = (*TaskService)(nil)
+
+func (f *TaskService) Create(ctx context.Context, in *task_service.CreateTask, _ ...grpc.CallOption) (*task_service.GetTask, error) {
+	f.record("Create", in)
+	if f.CreateFunc == nil {
+		return nil, unimplemented("TaskService", "Create")
+	}
+	return f.CreateFunc(ctx, in)
+}
+
+func (f *TaskService) GetByID(ctx context.Context, in *task_service.TaskPrimaryKey, _ ...grpc.CallOption) (*task_service.GetTask, error) {
+	f.record("GetByID", in)
+	if f.GetByIDFunc == nil {
+		return nil, unimplemented("TaskService", "GetByID")
+	}
+	return f.GetByIDFunc(ctx, in)
+}
+
+func (f *TaskService) GetByExternalId(ctx context.Context, in *task_service.TaskPrimaryKey, _ ...grpc.CallOption) (*task_service.GetTask, error) {
+	f.record("GetByExternalId", in)
+	if f.GetByExternalIdFunc == nil {
+		return nil, unimplemented("TaskService", "GetByExternalId")
+	}
+	return f.GetByExternalIdFunc(ctx, in)
+}
+
+func (f *TaskService) Update(ctx context.Context, in *task_service.UpdateTask, _ ...grpc.CallOption) (*task_service.GetTask, error) {
+	f.record("Update", in)
+	if f.UpdateFunc == nil {
+		return nil, unimplemented("TaskService", "Update")
+	}
+	return f.UpdateFunc(ctx, in)
+}
+
+func (f *TaskService) ChangeStatus(ctx context.Context, in *task_service.TaskChangeStatus, _ ...grpc.CallOption) (*task_service.TaskChangeStatusResp, error) {
+	f.record("ChangeStatus", in)
+	if f.ChangeStatusFunc == nil {
+		return nil, unimplemented("TaskService", "ChangeStatus")
+	}
+	return f.ChangeStatusFunc(ctx, in)
+}
+
+func (f *TaskService) Delete(ctx context.Context, in *task_service.TaskPrimaryKey, _ ...grpc.CallOption) (*empty.Empty, error) {
+	f.record("Delete", in)
+	if f.DeleteFunc == nil {
+		return nil, unimplemented("TaskService", "Delete")
+	}
+	return f.DeleteFunc(ctx, in)
+}
+
+func (f *TaskService) GetList(ctx context.Context, in *task_service.GetListTaskRequest, _ ...grpc.CallOption) (*task_service.GetListTaskResponse, error) {
+	f.record("GetList", in)
+	if f.GetListFunc == nil {
+		return nil, unimplemented("TaskService", "GetList")
+	}
+	return f.GetListFunc(ctx, in)
+}
@@ -0,0 +1,108 @@
+package fake
+
+import (
+	"api_gateway/genproto/user_service"
+	"context"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc"
+)
+
+// UserService is a user_service.UserServiceClient whose methods call the function of the same name.
+// Methods without a function fail with codes.Unimplemented.
+type UserService struct {
+	Recorder
+
+	CreateFunc          func(ctx context.Context, in *user_service.CreateUser) (*user_service.GetUser, error)
+	GetByIDFunc         func(ctx context.Context, in *user_service.UserPrimaryKey) (*user_service.GetUser, error)
+	GetListFunc         func(ctx context.Context, in *user_service.GetListUserRequest) (*user_service.GetListUserResponse, error)
+	UpdateFunc          func(ctx context.Context, in *user_service.UpdateUser) (*user_service.GetUser, error)
+	DeleteFunc          func(ctx context.Context, in *user_service.UserPrimaryKey) (*empty.Empty, error)
+	CheckFunc           func(ctx context.Context, in *user_service.UserPrimaryKey) (*user_service.CheckUserResp, error)
+	LoginFunc           func(ctx context.Context, in *user_service.UserLoginRequest) (*user_service.UserLoginResponse, error)
+	RegisterFunc        func(ctx context.Context, in *user_service.UserRegisterRequest) (*empty.Empty, error)
+	RegisterConfirmFunc func(ctx context.Context, in *user_service.UserRegisterConfRequest) (*user_service.UserLoginResponse, error)
+	ChangePasswordFunc  func(ctx context.Context, in *user_service.UserChangePassword) (*user_service.UserChangePasswordResp, error)
+}
+
+var _ user_service.UserServiceClient = (*UserService)(nil)
+
+func (f *UserService) Create(ctx context.Context, in *user_service.CreateUser, _ ...grpc.CallOption) (*user_service.GetUser, error) {
+	f.record("Create", in)
+	if f.CreateFunc == nil {
+		return nil, unimplemented("UserService", "Create")
+	}
+	return f.CreateFunc(ctx, in)
+}
+
+func (f *UserService) GetByID(ctx context.Context, in *user_service.UserPrimaryKey, _ ...grpc.CallOption) (*user_service.GetUser, error) {
+	f.record("GetByID", in)
+	if f.GetByIDFunc == nil {
+		return nil, unimplemented("UserService", "GetByID")
+	}
+	return f.GetByIDFunc(ctx, in)
+}
+
+func (f *UserService) GetList(ctx context.Context, in *user_service.GetListUserRequest, _ ...grpc.CallOption) (*user_service.GetListUserResponse, error) {
+	f.record("GetList", in)
+	if f.GetListFunc == nil {
+		return nil, unimplemented("UserService", "GetList")
+	}
+	return f.GetListFunc(ctx, in)
+}
+
+func (f *UserService) Update(ctx context.Context, in *user_service.UpdateUser, _ ...grpc.CallOption) (*user_service.GetUser, error) {
+	f.record("Update", in)
+	if f.UpdateFunc == nil {
+		return nil, unimplemented("UserService", "Update")
+	}
+	return f.UpdateFunc(ctx, in)
+}
+
+func (f *UserService) Delete(ctx context.Context, in *user_service.UserPrimaryKey, _ ...grpc.CallOption) (*empty.Empty, error) {
+	f.record("Delete", in)
+	if f.DeleteFunc == nil {
+		return nil, unimplemented("UserService", "Delete")
+	}
+	return f.DeleteFunc(ctx, in)
+}
+
+func (f *UserService) Check(ctx context.Context, in *user_service.UserPrimaryKey, _ ...grpc.CallOption) (*user_service.CheckUserResp, error) {
+	f.record("Check", in)
+	if f.CheckFunc == nil {
+		return nil, unimplemented("UserService", "Check")
+	}
+	return f.CheckFunc(ctx, in)
+}
+
+func (f *UserService) Login(ctx context.Context, in *user_service.UserLoginRequest, _ ...grpc.CallOption) (*user_service.UserLoginResponse, error) {
+	f.record("Login", in)
+	if f.LoginFunc == nil {
+		return nil, unimplemented("UserService", "Login")
+	}
+	return f.LoginFunc(ctx, in)
+}
+
+func (f *UserService) Register(ctx context.Context, in *user_service.UserRegisterRequest, _ ...grpc.CallOption) (*empty.Empty, error) {
+	f.record("Register", in)
+	if f.RegisterFunc == nil {
+		return nil, unimplemented("UserService", "Register")
+	}
+	return f.RegisterFunc(ctx, in)
+}
+
+func (f *UserService) RegisterConfirm(ctx context.Context, in *user_service.UserRegisterConfRequest, _ ...grpc.CallOption) (*user_service.UserLoginResponse, error) {
+	f.record("RegisterConfirm", in)
+	if f.RegisterConfirmFunc == nil {
+		return nil, unimplemented("UserService", "RegisterConfirm")
+	}
+	return f.RegisterConfirmFunc(ctx, in)
+}
+
+func (f *UserService) ChangePassword(ctx context.Context, in *user_service.UserChangePassword, _ ...grpc.CallOption) (*user_service.UserChangePasswordResp, error) {
+	f.record("ChangePassword", in)
+	if f.ChangePasswordFunc == nil {
+		return nil, unimplemented("UserService", "ChangePassword")
+	}
+	return f.ChangePasswordFunc(ctx, in)
+}
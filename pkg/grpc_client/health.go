@@ -0,0 +1,67 @@
+package grpc_client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Health is the health of the connection to a downstream service.
+type Health struct {
+	State string // connectivity state of the connection
+	Err   error  // nil when the service is serving
+}
+
+// CheckHealth probes every downstream connection with the standard gRPC health
+// checking protocol. Services that do not implement it are healthy while their
+// connection is ready. Idle connections are asked to connect.
+func (g *GrpcClient) CheckHealth(ctx context.Context) map[string]Health {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		health = make(map[string]Health, len(g.conns))
+	)
+
+	for service, conn := range g.conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := checkConn(ctx, service, conn)
+
+			mu.Lock()
+			defer mu.Unlock()
+			health[service] = Health{State: conn.GetState().String(), Err: err}
+		}()
+	}
+
+	wg.Wait()
+	return health
+}
+
+func checkConn(ctx context.Context, service string, conn *grpc.ClientConn) error {
+	if conn.GetState() == connectivity.Idle {
+		conn.Connect()
+	}
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	switch status.Code(err) {
+	case codes.OK:
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("%s is %s", service, resp.GetStatus())
+		}
+		return nil
+	case codes.Unimplemented:
+		if state := conn.GetState(); state != connectivity.Ready {
+			return fmt.Errorf("%s connection is %s", service, state)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s health check: %w", service, err)
+}
@@ -0,0 +1,28 @@
+package grpc_client
+
+import (
+	"api_gateway/pkg/metrics"
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// metricsInterceptor counts the calls per method and status code and
+// observes their latency. A call is counted once, however many attempts it took.
+func metricsInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		grpcService, rpcMethod, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+		service := serviceOf(method)
+		if service == "" {
+			service = grpcService
+		}
+		metrics.GRPCRequest(service, rpcMethod, status.Code(err).String(), time.Since(start))
+		return err
+	}
+}
@@ -0,0 +1,145 @@
+package grpc_client
+
+import (
+	"api_gateway/pkg/logger"
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// idempotentMethods are the read-only methods of the downstream services that are safe to call again.
+// Mutations are never retried, the first attempt may have been applied. Health
+// checks are not retried either, the startup and readiness checks poll them.
+var idempotentMethods = map[string]bool{
+	"GetByID":         true,
+	"GetList":         true,
+	"GetByExternalId": true,
+}
+
+// RetrySettings configures retries of idempotent calls.
+type RetrySettings struct {
+	// MaxAttempts is the number of attempts of a call, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the upper bound of the first wait, doubled for every further retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts.
+	MaxBackoff time.Duration
+}
+
+type retryBudgetKey struct{}
+
+// WithRetryBudget limits the retries of all calls made with ctx to n,
+// so one HTTP request cannot multiply the load on a struggling service.
+func WithRetryBudget(ctx context.Context, n int) context.Context {
+	budget := &atomic.Int64{}
+	budget.Store(int64(n))
+	return context.WithValue(ctx, retryBudgetKey{}, budget)
+}
+
+// takeRetry takes a retry from the budget of ctx, calls without a budget are only
+// limited by RetrySettings.MaxAttempts.
+func takeRetry(ctx context.Context) bool {
+	budget, ok := ctx.Value(retryBudgetKey{}).(*atomic.Int64)
+	if !ok {
+		return true
+	}
+	return budget.Add(-1) >= 0
+}
+
+// retryInterceptor retries idempotent calls failing with Unavailable or
+// DeadlineExceeded, waiting an exponential backoff with full jitter between
+// attempts. Calls rejected by an open circuit breaker are not retried.
+func retryInterceptor(settings RetrySettings, log logger.Logger) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if serviceOf(method) == "" || !idempotentMethods[methodName(method)] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		var err error
+		retries := 0
+		for attempt := 1; ; attempt++ {
+			err = invoker(ctx, method, req, reply, cc, opts...)
+			if attempt >= settings.MaxAttempts || !retryable(ctx, err) || !takeRetry(ctx) {
+				break
+			}
+
+			if !sleep(ctx, backoff(settings, attempt)) {
+				break
+			}
+			retries++
+			trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+				attribute.Int("attempt", attempt+1),
+				attribute.String("error", err.Error())))
+			logger.WithContext(ctx, log).Debug("retrying grpc call",
+				logger.String("method", method),
+				logger.Int("attempt", attempt+1),
+				logger.Error(err))
+		}
+
+		if retries > 0 {
+			logger.WithContext(ctx, log).Warn("grpc call retried",
+				logger.String("method", method),
+				logger.Int("retries", retries),
+				logger.String("code", status.Code(err).String()))
+		}
+		return err
+	}
+}
+
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var open *CircuitOpenError
+	if errors.As(err, &open) {
+		return false
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// backoff returns a random wait up to InitialBackoff doubled per previous attempt, capped at MaxBackoff.
+func backoff(settings RetrySettings, attempt int) time.Duration {
+	limit := settings.MaxBackoff
+	if shift := attempt - 1; shift < 32 {
+		if d := settings.InitialBackoff << shift; d > 0 && d < limit {
+			limit = d
+		}
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(limit) + 1))
+}
+
+// sleep waits for d, it reports false when ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// methodName returns the method of a full method name, e.g. "GetByID" for "/task_service_go.TaskService/GetByID".
+func methodName(method string) string {
+	return method[strings.LastIndex(method, "/")+1:]
+}
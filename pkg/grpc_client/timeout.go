@@ -0,0 +1,40 @@
+package grpc_client
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Timeouts resolves the deadline of a call from the most specific entry:
+// "<service>.<Method>", then "<Method>", then "<service>", then Default.
+type Timeouts struct {
+	Default time.Duration
+	ByName  map[string]time.Duration
+}
+
+func (t Timeouts) lookup(method string) time.Duration {
+	service, name := serviceOf(method), methodName(method)
+
+	for _, key := range []string{service + "." + name, name, service} {
+		if d, ok := t.ByName[key]; ok {
+			return d
+		}
+	}
+	return t.Default
+}
+
+// timeoutInterceptor puts a deadline on every call, so a hung service cannot
+// hold the HTTP request forever. An earlier deadline of the caller is kept.
+// The deadline covers all retries of the call.
+func timeoutInterceptor(timeouts Timeouts) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if d := timeouts.lookup(method); d > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
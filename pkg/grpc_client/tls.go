@@ -0,0 +1,160 @@
+package grpc_client
+
+import (
+	"api_gateway/config"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// transportCredentials returns the credentials of a connection for its TLS configuration.
+func transportCredentials(cfg config.TLSConfig) (credentials.TransportCredentials, error) {
+	if cfg.Mode == config.TLSModePlaintext || cfg.Mode == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	r := &reloadingCredentials{cfg: cfg}
+	// fail at startup rather than on the first call when the files are wrong
+	if _, err := r.current(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reloadingCredentials are TLS credentials rebuilt from the CA, certificate and
+// key files whenever one of them changes on disk, so rotated certificates are
+// used by new connections without a restart.
+type reloadingCredentials struct {
+	cfg config.TLSConfig
+
+	mu       sync.Mutex
+	creds    credentials.TransportCredentials
+	modTimes map[string]time.Time
+}
+
+func (r *reloadingCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	creds, err := r.current()
+	if err != nil {
+		return nil, nil, err
+	}
+	return creds.ClientHandshake(ctx, authority, conn)
+}
+
+func (r *reloadingCredentials) ServerHandshake(net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("grpc_client: server handshake is not supported")
+}
+
+func (r *reloadingCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{
+		SecurityProtocol: "tls",
+		SecurityVersion:  "1.2",
+		ServerName:       r.cfg.ServerName,
+	}
+}
+
+func (r *reloadingCredentials) Clone() credentials.TransportCredentials {
+	return &reloadingCredentials{cfg: r.cfg}
+}
+
+// OverrideServerName is deprecated in grpc, it is kept to satisfy the interface.
+func (r *reloadingCredentials) OverrideServerName(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cfg.ServerName = name
+	r.creds = nil
+	return nil
+}
+
+// current returns the credentials, rebuilt when a file has changed. When the
+// changed files cannot be loaded, e.g. while they are being replaced, the
+// previous credentials are kept.
+func (r *reloadingCredentials) current() (credentials.TransportCredentials, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.modTimesOf()
+	if err == nil && r.creds != nil && sameModTimes(modTimes, r.modTimes) {
+		return r.creds, nil
+	}
+
+	if err == nil {
+		var tlsCfg *tls.Config
+		tlsCfg, err = buildTLSConfig(r.cfg)
+		if err == nil {
+			r.creds = credentials.NewTLS(tlsCfg)
+			r.modTimes = modTimes
+			return r.creds, nil
+		}
+	}
+
+	if r.creds != nil {
+		return r.creds, nil
+	}
+	return nil, err
+}
+
+func (r *reloadingCredentials) modTimesOf() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time, 3)
+	for _, file := range []string{r.cfg.CAFile, r.cfg.CertFile, r.cfg.KeyFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[file] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func sameModTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, t := range a {
+		if !b[file].Equal(t) {
+			return false
+		}
+	}
+	return true
+}
+
+func buildTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+	}
+
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("tls ca %s: no PEM certificates", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.Mode == config.TLSModeMTLS {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
@@ -0,0 +1,334 @@
+package grpc_client
+
+import (
+	"api_gateway/config"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serviceName is the name in the certificates of the test services.
+const serviceName = "service.test"
+
+var serial atomic.Int64
+
+// testCA signs the certificates of a test.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial.Add(1)),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate of the CA for serviceName and its key as PEM.
+func (ca *testCA) issue(t *testing.T, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial.Add(1)),
+		Subject:      pkix.Name{CommonName: serviceName},
+		DNSNames:     []string{serviceName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) keyPair(t *testing.T, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+
+	cert, err := tls.X509KeyPair(ca.issue(t, usage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// writeFile writes a file and moves its modification time forward, so a
+// rewrite within the resolution of the file system is seen as a change.
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(time.Duration(serial.Add(1)) * time.Second)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tlsServer is a gRPC health service behind TLS on bufconn.
+type tlsServer struct {
+	listener *bufconn.Listener
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+// newTLSServer serves cert, it requires client certificates of clientCA when it is not nil.
+func newTLSServer(t *testing.T, cert tls.Certificate, clientCA *testCA) *tlsServer {
+	t.Helper()
+
+	s := &tlsServer{listener: bufconn.Listen(1 << 16)}
+	s.cert.Store(&cert)
+
+	tlsCfg := &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.cert.Load(), nil
+		},
+	}
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA.cert)
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsCfg)))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(s.listener)
+	t.Cleanup(server.Stop)
+	return s
+}
+
+// check calls the service on a new connection, so every call makes a handshake.
+func (s *tlsServer) check(t *testing.T, creds credentials.TransportCredentials) error {
+	t.Helper()
+
+	conn, err := grpc.NewClient("passthrough:///"+serviceName,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.listener.DialContext(ctx)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+// tlsFiles are the paths of the client files of a test.
+type tlsFiles struct {
+	ca, cert, key string
+}
+
+func newTLSFiles(t *testing.T) tlsFiles {
+	dir := t.TempDir()
+	return tlsFiles{
+		ca:   filepath.Join(dir, "ca.pem"),
+		cert: filepath.Join(dir, "client.pem"),
+		key:  filepath.Join(dir, "client-key.pem"),
+	}
+}
+
+func (f tlsFiles) config(mode string) config.TLSConfig {
+	cfg := config.TLSConfig{Mode: mode, CAFile: f.ca, ServerName: serviceName}
+	if mode == config.TLSModeMTLS {
+		cfg.CertFile, cfg.KeyFile = f.cert, f.key
+	}
+	return cfg
+}
+
+func (f tlsFiles) writeClientCert(t *testing.T, ca *testCA) {
+	t.Helper()
+
+	cert, key := ca.issue(t, x509.ExtKeyUsageClientAuth)
+	writeFile(t, f.cert, cert)
+	writeFile(t, f.key, key)
+}
+
+func credentialsFor(t *testing.T, cfg config.TLSConfig) credentials.TransportCredentials {
+	t.Helper()
+
+	creds, err := transportCredentials(cfg)
+	if err != nil {
+		t.Fatalf("transport credentials: %v", err)
+	}
+	return creds
+}
+
+func TestTLSHandshake(t *testing.T) {
+	ca := newTestCA(t)
+	server := newTLSServer(t, ca.keyPair(t, x509.ExtKeyUsageServerAuth), nil)
+
+	files := newTLSFiles(t)
+	writeFile(t, files.ca, ca.pem)
+	if err := server.check(t, credentialsFor(t, files.config(config.TLSModeTLS))); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+
+	other := newTLSFiles(t)
+	writeFile(t, other.ca, newTestCA(t).pem)
+	if err := server.check(t, credentialsFor(t, other.config(config.TLSModeTLS))); err == nil {
+		t.Fatal("server of another CA trusted")
+	}
+}
+
+func TestMTLSHandshake(t *testing.T) {
+	ca, clientCA := newTestCA(t), newTestCA(t)
+	server := newTLSServer(t, ca.keyPair(t, x509.ExtKeyUsageServerAuth), clientCA)
+
+	files := newTLSFiles(t)
+	writeFile(t, files.ca, ca.pem)
+	files.writeClientCert(t, clientCA)
+
+	if err := server.check(t, credentialsFor(t, files.config(config.TLSModeMTLS))); err != nil {
+		t.Fatalf("call with a client certificate: %v", err)
+	}
+	if err := server.check(t, credentialsFor(t, files.config(config.TLSModeTLS))); err == nil {
+		t.Fatal("call without a client certificate accepted")
+	}
+
+	files.writeClientCert(t, newTestCA(t))
+	if err := server.check(t, credentialsFor(t, files.config(config.TLSModeMTLS))); err == nil {
+		t.Fatal("client certificate of another CA accepted")
+	}
+}
+
+func TestTLSReloadsChangedFiles(t *testing.T) {
+	ca, clientCA := newTestCA(t), newTestCA(t)
+	server := newTLSServer(t, ca.keyPair(t, x509.ExtKeyUsageServerAuth), clientCA)
+
+	files := newTLSFiles(t)
+	writeFile(t, files.ca, ca.pem)
+	files.writeClientCert(t, clientCA)
+	creds := credentialsFor(t, files.config(config.TLSModeMTLS))
+	if err := server.check(t, creds); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+
+	// the service moves to a new CA, the gateway trusts it once the CA file is replaced
+	rotated := newTestCA(t)
+	server.cert.Store(ptr(rotated.keyPair(t, x509.ExtKeyUsageServerAuth)))
+	if err := server.check(t, creds); err == nil {
+		t.Fatal("server of the new CA trusted before the CA file changed")
+	}
+	writeFile(t, files.ca, rotated.pem)
+	if err := server.check(t, creds); err != nil {
+		t.Fatalf("call after the CA file changed: %v", err)
+	}
+
+	// a client certificate of another CA is sent once its files are replaced
+	files.writeClientCert(t, newTestCA(t))
+	if err := server.check(t, creds); err == nil {
+		t.Fatal("client certificate not reloaded")
+	}
+}
+
+func TestTLSKeepsCredentialsWhenFilesFailToLoad(t *testing.T) {
+	ca, clientCA := newTestCA(t), newTestCA(t)
+	server := newTLSServer(t, ca.keyPair(t, x509.ExtKeyUsageServerAuth), clientCA)
+
+	files := newTLSFiles(t)
+	writeFile(t, files.ca, ca.pem)
+	files.writeClientCert(t, clientCA)
+	creds := credentialsFor(t, files.config(config.TLSModeMTLS))
+
+	for _, tc := range []struct {
+		name   string
+		change func()
+	}{
+		{"invalid CA", func() { writeFile(t, files.ca, []byte("not a certificate")) }},
+		{"key of another certificate", func() {
+			_, key := clientCA.issue(t, x509.ExtKeyUsageClientAuth)
+			writeFile(t, files.key, key)
+		}},
+		{"missing key", func() {
+			if err := os.Remove(files.key); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	} {
+		tc.change()
+		if err := server.check(t, creds); err != nil {
+			t.Fatalf("%s: call: %v", tc.name, err)
+		}
+	}
+
+	// a complete set of files is loaded again
+	rotated := newTestCA(t)
+	server.cert.Store(ptr(rotated.keyPair(t, x509.ExtKeyUsageServerAuth)))
+	writeFile(t, files.ca, rotated.pem)
+	files.writeClientCert(t, clientCA)
+	if err := server.check(t, creds); err != nil {
+		t.Fatalf("call after the files are fixed: %v", err)
+	}
+}
+
+func TestTLSFailsAtStartup(t *testing.T) {
+	files := newTLSFiles(t)
+	if _, err := transportCredentials(files.config(config.TLSModeTLS)); err == nil {
+		t.Fatal("missing CA file accepted")
+	}
+
+	writeFile(t, files.ca, newTestCA(t).pem)
+	if _, err := transportCredentials(files.config(config.TLSModeMTLS)); err == nil {
+		t.Fatal("missing client certificate accepted")
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -1,22 +1,115 @@
 package jwt
 
 import (
-	"api_gateway/config"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"math"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v4"
 )
 
+const (
+	// AccessTokenType is the "typ" claim of short-lived access tokens.
+	AccessTokenType = "access"
+	// RefreshTokenType is the "typ" claim of long-lived refresh tokens.
+	RefreshTokenType = "refresh"
+
+	// AccessTokenTTL is the lifetime of an access token.
+	AccessTokenTTL = 24 * time.Hour
+	// RefreshTokenTTL is the lifetime of a refresh token.
+	RefreshTokenTTL = 10 * 24 * time.Hour
+
+	// legacyRefreshLifetime tells apart the tokens issued before the "typ"
+	// claim existed: 1 day access and 10 day refresh tokens. It is fixed halfway
+	// between the two, so a change of AccessTokenTTL or RefreshTokenTTL never
+	// turns those tokens into the other type.
+	legacyRefreshLifetime = 132 * time.Hour
+)
+
+// registeredClaims are set by GenJWT itself and never copied from an old token.
+var registeredClaims = map[string]bool{
+	"iss": true, "iat": true, "exp": true, "nbf": true, "jti": true, "typ": true,
+}
+
+var (
+	// signedKey is the HS256 secret shared with the backend services.
+	signedKey []byte
+	// keySet signs and verifies tokens with asymmetric keys once set.
+	// Without it tokens are signed with signedKey.
+	keySet *KeySet
+	// acceptSharedSecret keeps HS256 tokens without a kid valid while keySet is set.
+	acceptSharedSecret bool
+)
+
+// SetSigningKey sets the HS256 secret shared with the backend services.
+func SetSigningKey(key []byte) {
+	signedKey = key
+}
+
+// SetKeySet switches GenJWT to asymmetric signing with the active key of ks.
+// HS256 tokens without a kid are refused from then on, the tokens of the
+// backend services are exchanged by ReissueSharedSecretTokens instead.
+func SetKeySet(ks *KeySet) {
+	keySet = ks
+}
+
+// AcceptSharedSecretTokens keeps HS256 tokens without a kid valid while a key
+// set is loaded. It is meant for the migration to a key set only, until the
+// tokens issued before have expired.
+func AcceptSharedSecretTokens(accept bool) {
+	acceptSharedSecret = accept
+}
+
+// ReissueSharedSecretTokens exchanges the HS256 tokens the backend services
+// sign with the shared secret for tokens signed by the key set, keeping their
+// custom claims. Tokens are returned as they are without a key set.
+func ReissueSharedSecretTokens(accessToken, refreshToken string) (string, string, error) {
+	if keySet == nil {
+		return accessToken, refreshToken, nil
+	}
+
+	token, err := jwt.Parse(accessToken, sharedSecretKey)
+	if err != nil {
+		return "", "", fmt.Errorf("token of the service: %w", err)
+	}
+	if kid, _ := token.Header["kid"].(string); kid != "" {
+		// signed by the key set already
+		return accessToken, refreshToken, nil
+	}
+	return GenJWT(CustomClaims(token.Claims.(jwt.MapClaims)))
+}
+
+// PublicKeys returns the verification keys published at /.well-known/jwks.json.
+func PublicKeys() JWKS {
+	if keySet == nil {
+		return JWKS{Keys: []JWK{}}
+	}
+	return keySet.JWKS()
+}
+
 func GenJWT(m map[interface{}]interface{}) (string, string, error) {
 	var (
 		accessToken, refreshToken *jwt.Token
 		claims                    jwt.MapClaims
+		signKey                   interface{} = signedKey
 	)
 
 	accessToken = jwt.New(jwt.SigningMethodHS256)
 	refreshToken = jwt.New(jwt.SigningMethodHS256)
 
+	if keySet != nil {
+		key := keySet.Active()
+		signKey = key.Private
+
+		accessToken = jwt.New(key.Method)
+		refreshToken = jwt.New(key.Method)
+		accessToken.Header["kid"] = key.ID
+		refreshToken.Header["kid"] = key.ID
+	}
+
 	claims = accessToken.Claims.(jwt.MapClaims)
 	rClaims := refreshToken.Claims.(jwt.MapClaims)
 
@@ -25,21 +118,30 @@ func GenJWT(m map[interface{}]interface{}) (string, string, error) {
 		rClaims[k.(string)] = v
 	}
 
+	now := time.Now()
+	// iat has microseconds, so a logout-all revokes the tokens issued before it
+	// and spares those of a login in the same second
+	iat := float64(now.UnixMicro()) / 1e6
+
 	claims["iss"] = "user"
-	claims["iat"] = time.Now().Unix()
-	claims["exp"] = time.Now().AddDate(0, 0, 1).Unix()
+	claims["iat"] = iat
+	claims["exp"] = now.Add(AccessTokenTTL).Unix()
+	claims["typ"] = AccessTokenType
+	claims["jti"] = newTokenID()
 
 	rClaims["iss"] = "user"
-	rClaims["iat"] = time.Now().Unix()
-	rClaims["exp"] = time.Now().AddDate(0, 0, 10).Unix()
+	rClaims["iat"] = iat
+	rClaims["exp"] = now.Add(RefreshTokenTTL).Unix()
+	rClaims["typ"] = RefreshTokenType
+	rClaims["jti"] = newTokenID()
 
-	accessTokenString, err := accessToken.SignedString(config.SignedKey)
+	accessTokenString, err := accessToken.SignedString(signKey)
 	if err != nil {
 		err = fmt.Errorf("access_token generating error: %s", err)
 		return "", "", err
 	}
 
-	refreshTokenString, err := refreshToken.SignedString(config.SignedKey)
+	refreshTokenString, err := refreshToken.SignedString(signKey)
 	if err != nil {
 		err = fmt.Errorf("refresh_token generating error: %s", err)
 		return "", "", err
@@ -49,22 +151,9 @@ func GenJWT(m map[interface{}]interface{}) (string, string, error) {
 }
 
 func ExtractClaims(tokenStr string) (jwt.MapClaims, error) {
-	var (
-		token *jwt.Token
-		err   error
-	)
-	token, err = jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		// check token signing method etc
-		return config.SignedKey, nil
-	})
+	token, err := jwt.Parse(tokenStr, verificationKey)
 	if err != nil {
-		token, err = jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			// check token signing method etc
-			return config.SignedKey, nil
-		})
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
@@ -74,3 +163,94 @@ func ExtractClaims(tokenStr string) (jwt.MapClaims, error) {
 	}
 	return claims, nil
 }
+
+// verificationKey picks the key of a token: a key of the key set by "kid",
+// or the shared HS256 secret for tokens without one while there is no key set.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && keySet != nil && !acceptSharedSecret {
+		return nil, fmt.Errorf("token has no key id")
+	}
+	return sharedSecretKey(token)
+}
+
+// sharedSecretKey is verificationKey accepting HS256 tokens without a kid.
+func sharedSecretKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return signedKey, nil
+	}
+
+	if keySet == nil {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	key, ok := keySet.Lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", token.Header["alg"], kid)
+	}
+	return key.Public, nil
+}
+
+// CustomClaims returns the application claims (user_id, user_role, ...) of a token
+// in the form accepted by GenJWT, so a token pair can be reissued from them.
+func CustomClaims(claims jwt.MapClaims) map[interface{}]interface{} {
+	m := make(map[interface{}]interface{}, len(claims))
+	for k, v := range claims {
+		if registeredClaims[k] {
+			continue
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// TokenType reports whether claims belong to an access or a refresh token.
+// Tokens issued before the "typ" claim existed are told apart by their
+// lifetime, see legacyRefreshLifetime.
+func TokenType(claims jwt.MapClaims) string {
+	if typ, ok := claims["typ"].(string); ok && typ != "" {
+		return typ
+	}
+
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+	if time.Duration((exp-iat)*float64(time.Second)) >= legacyRefreshLifetime {
+		return RefreshTokenType
+	}
+	return AccessTokenType
+}
+
+// TokenID returns the "jti" claim, or a digest of the raw token for tokens without one.
+func TokenID(claims jwt.MapClaims, tokenStr string) string {
+	if jti, ok := claims["jti"].(string); ok && jti != "" {
+		return jti
+	}
+	sum := sha256.Sum256([]byte(tokenStr))
+	return hex.EncodeToString(sum[:])
+}
+
+// ExpiresAt returns the "exp" claim as time.
+func ExpiresAt(claims jwt.MapClaims) time.Time {
+	exp, _ := claims["exp"].(float64)
+	return time.Unix(int64(exp), 0)
+}
+
+// IssuedAt returns the "iat" claim as time, with the microseconds of the tokens of GenJWT.
+func IssuedAt(claims jwt.MapClaims) time.Time {
+	iat, _ := claims["iat"].(float64)
+	return time.UnixMicro(int64(math.Round(iat * 1e6)))
+}
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
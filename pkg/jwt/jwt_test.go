@@ -0,0 +1,34 @@
+package jwt_test
+
+import (
+	"api_gateway/pkg/jwt"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+)
+
+func TestTokenType(t *testing.T) {
+	iat := float64(time.Now().Unix())
+	lifetime := func(d time.Duration) gojwt.MapClaims {
+		return gojwt.MapClaims{"iat": iat, "exp": iat + d.Seconds()}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		claims gojwt.MapClaims
+		want   string
+	}{
+		{"typ access", gojwt.MapClaims{"typ": jwt.AccessTokenType, "iat": iat, "exp": iat + 1e6}, jwt.AccessTokenType},
+		{"typ refresh", gojwt.MapClaims{"typ": jwt.RefreshTokenType, "iat": iat, "exp": iat + 1}, jwt.RefreshTokenType},
+		{"legacy access", lifetime(24 * time.Hour), jwt.AccessTokenType},
+		{"legacy refresh", lifetime(240 * time.Hour), jwt.RefreshTokenType},
+		// the threshold is halfway, not the access token lifetime
+		{"legacy 2 days", lifetime(48 * time.Hour), jwt.AccessTokenType},
+		{"legacy 8 days", lifetime(192 * time.Hour), jwt.RefreshTokenType},
+	} {
+		if got := jwt.TokenType(tc.claims); got != tc.want {
+			t.Errorf("%s: TokenType = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Key is an asymmetric signing key identified by its "kid".
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+
+	loadedAt time.Time
+}
+
+// KeySet holds the signing keys read from a directory of PEM files.
+// Every key verifies tokens and is published in the JWKS; the newest one
+// (by kid, i.e. file name) that has been published for a whole rotation
+// interval signs new tokens, so verifiers can fetch it before it is used.
+type KeySet struct {
+	dir      string
+	interval time.Duration
+
+	mu   sync.RWMutex
+	keys map[string]*Key
+}
+
+// LoadKeySet reads every *.pem private key (RSA or Ed25519) from dir.
+// The file name without extension becomes the key id.
+func LoadKeySet(dir string, interval time.Duration) (*KeySet, error) {
+	ks := &KeySet{
+		dir:      dir,
+		interval: interval,
+		keys:     make(map[string]*Key),
+	}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+
+	// keys present at startup are considered published already
+	for _, k := range ks.keys {
+		k.loadedAt = time.Time{}
+	}
+	return ks, nil
+}
+
+// Reload picks up keys added to and drops keys removed from the directory.
+func (ks *KeySet) Reload() error {
+	files, err := filepath.Glob(filepath.Join(ks.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("jwt: no *.pem keys in %s", ks.dir)
+	}
+
+	ks.mu.RLock()
+	old := ks.keys
+	ks.mu.RUnlock()
+
+	keys := make(map[string]*Key, len(files))
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if k, ok := old[kid]; ok {
+			keys[kid] = k
+			continue
+		}
+
+		k, err := readKey(file)
+		if err != nil {
+			return err
+		}
+		k.ID = kid
+		k.loadedAt = time.Now()
+		keys[kid] = k
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+	return nil
+}
+
+// Run reloads the key directory every rotation interval until ctx is done.
+func (ks *KeySet) Run(ctx context.Context, onError func(error)) {
+	ticker := time.NewTicker(ks.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Active returns the key that signs new tokens.
+func (ks *KeySet) Active() *Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	ids := ks.sortedIDs()
+	publishedBefore := time.Now().Add(-ks.interval)
+	for i := len(ids) - 1; i >= 0; i-- {
+		if k := ks.keys[ids[i]]; !k.loadedAt.After(publishedBefore) {
+			return k
+		}
+	}
+	// nothing has been published long enough, fall back to the newest key
+	return ks.keys[ids[len(ids)-1]]
+}
+
+// Lookup returns the key with the given id.
+func (ks *KeySet) Lookup(kid string) (*Key, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// JWKS returns the public keys in JSON Web Key Set format.
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, id := range ks.sortedIDs() {
+		set.Keys = append(set.Keys, ks.keys[id].jwk())
+	}
+	return set
+}
+
+func (ks *KeySet) sortedIDs() []string {
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// JWKS is a JSON Web Key Set (RFC 7517).
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is the public part of a signing key (RFC 7517, RFC 8037).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+func (k *Key) jwk() JWK {
+	jwk := JWK{
+		Kid: k.ID,
+		Use: "sig",
+		Alg: k.Method.Alg(),
+	}
+
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+func readKey(file string) (*Key, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("jwt: %s is not a PEM file", file)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		err = fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwt: %s: %w", file, err)
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &Key{Method: jwt.SigningMethodRS256, Private: key, Public: &key.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{Method: jwt.SigningMethodEdDSA, Private: key, Public: key.Public()}, nil
+	}
+	return nil, fmt.Errorf("jwt: %s: %w", file, errors.New("only RSA and Ed25519 keys are supported"))
+}
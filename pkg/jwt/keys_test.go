@@ -0,0 +1,303 @@
+package jwt_test
+
+import (
+	"api_gateway/pkg/jwt"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+)
+
+// writeRSAKey writes a new RSA key as PKCS #1 PEM to dir/kid.pem.
+func writeRSAKey(t *testing.T, dir, kid string) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, kid, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return key
+}
+
+// writeEd25519Key writes a new Ed25519 key as PKCS #8 PEM to dir/kid.pem.
+func writeEd25519Key(t *testing.T, dir, kid string) ed25519.PrivateKey {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, kid, &pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return key
+}
+
+func writePEM(t *testing.T, dir, kid string, block *pem.Block) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useKeySet signs the tokens of the test with ks and restores HS256 signing afterwards.
+func useKeySet(t *testing.T, ks *jwt.KeySet) {
+	t.Helper()
+
+	jwt.SetSigningKey([]byte("jwt-test-signing-key"))
+	jwt.SetKeySet(ks)
+	t.Cleanup(func() { jwt.SetKeySet(nil) })
+}
+
+func loadKeySet(t *testing.T, dir string, interval time.Duration) *jwt.KeySet {
+	t.Helper()
+
+	ks, err := jwt.LoadKeySet(dir, interval)
+	if err != nil {
+		t.Fatalf("load key set: %v", err)
+	}
+	return ks
+}
+
+// issue returns an access token of a test user signed by the current key.
+func issue(t *testing.T) string {
+	t.Helper()
+
+	access, _, err := jwt.GenJWT(map[interface{}]interface{}{"user_id": "jwt-test", "user_role": "user"})
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	return access
+}
+
+func header(t *testing.T, token string) map[string]interface{} {
+	t.Helper()
+
+	parsed, _, err := new(gojwt.Parser).ParseUnverified(token, gojwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	return parsed.Header
+}
+
+func TestSignAndVerifyWithKeySet(t *testing.T) {
+	for _, tc := range []struct {
+		alg   string
+		write func(t *testing.T, dir, kid string)
+	}{
+		{"RS256", func(t *testing.T, dir, kid string) { writeRSAKey(t, dir, kid) }},
+		{"EdDSA", func(t *testing.T, dir, kid string) { writeEd25519Key(t, dir, kid) }},
+	} {
+		t.Run(tc.alg, func(t *testing.T) {
+			dir := t.TempDir()
+			tc.write(t, dir, "2024-01")
+			useKeySet(t, loadKeySet(t, dir, time.Hour))
+
+			token := issue(t)
+			if h := header(t, token); h["kid"] != "2024-01" || h["alg"] != tc.alg {
+				t.Fatalf("header = %v, want kid 2024-01 and alg %s", h, tc.alg)
+			}
+
+			claims, err := jwt.ExtractClaims(token)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if claims["user_id"] != "jwt-test" || jwt.TokenType(claims) != jwt.AccessTokenType {
+				t.Fatalf("claims = %v", claims)
+			}
+
+			// same kid, another key
+			tampered := t.TempDir()
+			tc.write(t, tampered, "2024-01")
+			useKeySet(t, loadKeySet(t, tampered, time.Hour))
+			if _, err := jwt.ExtractClaims(token); err == nil {
+				t.Fatal("token verified with the wrong key")
+			}
+		})
+	}
+}
+
+func TestUnknownKeyID(t *testing.T) {
+	other := t.TempDir()
+	writeEd25519Key(t, other, "other")
+	useKeySet(t, loadKeySet(t, other, time.Hour))
+	token := issue(t)
+
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "current")
+	useKeySet(t, loadKeySet(t, dir, time.Hour))
+
+	if _, err := jwt.ExtractClaims(token); err == nil || !strings.Contains(err.Error(), `unknown key id "other"`) {
+		t.Fatalf("err = %v, want unknown key id", err)
+	}
+}
+
+func TestSharedSecretTokensWithKeySet(t *testing.T) {
+	jwt.SetSigningKey([]byte("jwt-test-signing-key"))
+	jwt.SetKeySet(nil)
+	access, refresh, err := jwt.GenJWT(map[interface{}]interface{}{"user_id": "jwt-test", "user_role": "user"})
+	if err != nil {
+		t.Fatalf("issue tokens: %v", err)
+	}
+
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "rsa")
+	useKeySet(t, loadKeySet(t, dir, time.Hour))
+
+	if _, err := jwt.ExtractClaims(access); err == nil || !strings.Contains(err.Error(), "token has no key id") {
+		t.Fatalf("err = %v, want HS256 token without kid refused", err)
+	}
+
+	// the tokens of the services are exchanged for tokens of the key set
+	reissued, _, err := jwt.ReissueSharedSecretTokens(access, refresh)
+	if err != nil {
+		t.Fatalf("reissue: %v", err)
+	}
+	if h := header(t, reissued); h["kid"] != "rsa" {
+		t.Fatalf("header = %v, want kid rsa", h)
+	}
+	if claims, err := jwt.ExtractClaims(reissued); err != nil || claims["user_id"] != "jwt-test" {
+		t.Fatalf("claims = %v, %v", claims, err)
+	}
+	if again, _, err := jwt.ReissueSharedSecretTokens(reissued, ""); err != nil || again != reissued {
+		t.Fatalf("tokens of the key set reissued: %v", err)
+	}
+
+	jwt.AcceptSharedSecretTokens(true)
+	t.Cleanup(func() { jwt.AcceptSharedSecretTokens(false) })
+	if _, err := jwt.ExtractClaims(access); err != nil {
+		t.Fatalf("HS256 token without kid while migrating: %v", err)
+	}
+}
+
+func TestNewKeyIsActiveAfterOneInterval(t *testing.T) {
+	const interval = 200 * time.Millisecond
+
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "a")
+	ks := loadKeySet(t, dir, interval)
+	if id := ks.Active().ID; id != "a" {
+		t.Fatalf("active = %q, want a", id)
+	}
+
+	writeEd25519Key(t, dir, "b")
+	if err := ks.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ks.Lookup("b"); !ok {
+		t.Fatal("b is not loaded")
+	}
+	// published in the JWKS, but verifiers may not have fetched it yet
+	if id := ks.Active().ID; id != "a" {
+		t.Fatalf("active = %q before one interval, want a", id)
+	}
+
+	time.Sleep(interval + 50*time.Millisecond)
+	if id := ks.Active().ID; id != "b" {
+		t.Fatalf("active = %q after one interval, want b", id)
+	}
+}
+
+func TestKeysPresentAtStartupAreActive(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "a")
+	writeEd25519Key(t, dir, "b")
+
+	if id := loadKeySet(t, dir, time.Hour).Active().ID; id != "b" {
+		t.Fatalf("active = %q, want the newest key b", id)
+	}
+}
+
+func TestReloadDropsRemovedKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "a")
+	writeEd25519Key(t, dir, "b")
+	ks := loadKeySet(t, dir, time.Hour)
+	useKeySet(t, ks)
+	token := issue(t) // signed by b
+
+	if err := os.Remove(filepath.Join(dir, "b.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := ks.Lookup("b"); ok {
+		t.Fatal("b is still loaded")
+	}
+	if id := ks.Active().ID; id != "a" {
+		t.Fatalf("active = %q, want a", id)
+	}
+	if _, err := jwt.ExtractClaims(token); err == nil {
+		t.Fatal("token of a removed key verified")
+	}
+	if keys := ks.JWKS().Keys; len(keys) != 1 || keys[0].Kid != "a" {
+		t.Fatalf("JWKS = %+v, want a only", keys)
+	}
+}
+
+func TestLoadKeySetErrors(t *testing.T) {
+	if _, err := jwt.LoadKeySet(t.TempDir(), time.Hour); err == nil {
+		t.Fatal("empty directory accepted")
+	}
+
+	dir := t.TempDir()
+	writePEM(t, dir, "cert", &pem.Block{Type: "CERTIFICATE", Bytes: []byte("not a key")})
+	if _, err := jwt.LoadKeySet(dir, time.Hour); err == nil {
+		t.Fatal("certificate accepted as a key")
+	}
+}
+
+func TestJWKSEncoding(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := writeRSAKey(t, dir, "a-rsa")
+	edKey := writeEd25519Key(t, dir, "b-ed25519")
+
+	keys := loadKeySet(t, dir, time.Hour).JWKS().Keys
+	if len(keys) != 2 {
+		t.Fatalf("JWKS = %+v, want 2 keys", keys)
+	}
+
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatalf("decode %q: %v", s, err)
+		}
+		return b
+	}
+
+	r := keys[0]
+	if r.Kid != "a-rsa" || r.Kty != "RSA" || r.Alg != "RS256" || r.Use != "sig" {
+		t.Fatalf("RSA JWK = %+v", r)
+	}
+	if n := new(big.Int).SetBytes(decode(r.N)); n.Cmp(rsaKey.N) != 0 {
+		t.Fatal("RSA modulus differs")
+	}
+	if e := new(big.Int).SetBytes(decode(r.E)); e.Int64() != int64(rsaKey.E) {
+		t.Fatalf("RSA exponent = %v, want %d", e, rsaKey.E)
+	}
+
+	e := keys[1]
+	if e.Kid != "b-ed25519" || e.Kty != "OKP" || e.Crv != "Ed25519" || e.Alg != "EdDSA" || e.Use != "sig" {
+		t.Fatalf("Ed25519 JWK = %+v", e)
+	}
+	if !bytes.Equal(decode(e.X), edKey.Public().(ed25519.PublicKey)) {
+		t.Fatal("Ed25519 public key differs")
+	}
+}
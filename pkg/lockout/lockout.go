@@ -0,0 +1,164 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// BackendMemory keeps failure records in the gateway process.
+	BackendMemory = "memory"
+	// BackendRedis keeps failure records in Redis, shared by all gateway replicas.
+	BackendRedis = "redis"
+)
+
+// ValidateBackend checks the LOGIN_LOCKOUT_BACKEND setting before the gateway starts.
+func ValidateBackend(backend string) error {
+	if backend != BackendMemory && backend != BackendRedis {
+		return fmt.Errorf("LOGIN_LOCKOUT_BACKEND must be memory or redis, got %q", backend)
+	}
+	return nil
+}
+
+// Policy decides how failed attempts on a key are throttled.
+//
+// The first failure is free, every further one delays the next attempt by
+// BaseDelay doubled per failure up to MaxDelay. MaxFailures failures lock
+// the key for Duration. Failures older than Window are forgotten.
+// No more than MaxInFlight attempts on the key may be pending, 0 is unlimited.
+type Policy struct {
+	MaxFailures int
+	Duration    time.Duration
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Window      time.Duration
+	MaxInFlight int
+}
+
+// Outcome is how a reserved attempt ended.
+type Outcome int
+
+const (
+	// Failed keeps the failure counted by the reservation.
+	Failed Outcome = iota
+	// Refunded takes the failure back, e.g. when the service could not answer.
+	Refunded
+	// Succeeded forgets all failures of the key.
+	Succeeded
+)
+
+// Entry is the failure record of a key, e.g. a login name or a client address.
+type Entry struct {
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	InFlight    int       `json:"in_flight"`
+	LastFailure time.Time `json:"last_failure"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// Locked reports whether the key is locked out at now.
+func (e Entry) Locked(now time.Time) bool {
+	return e.LockedUntil.After(now)
+}
+
+// Delayed reports whether the next attempt of the key has to wait at now.
+func (e Entry) Delayed(now time.Time) bool {
+	return e.NextAttempt.After(now)
+}
+
+// Store keeps failure records.
+//
+// Attempts are counted as failed before they are made, so parallel attempts
+// cannot slip past the lockout: Reserve checks the record and counts the
+// failure in one step, Settle refunds it when the attempt did not fail.
+type Store interface {
+	// Get returns the record of key, an empty one when it has no recent failures.
+	Get(ctx context.Context, key string) (Entry, error)
+	// Reserve counts a pending attempt on key as failed and returns the
+	// updated record. It refuses the attempt, reporting false and changing
+	// nothing, while the key is locked out, delayed or has MaxInFlight
+	// pending attempts. A granted attempt must be settled.
+	Reserve(ctx context.Context, key string, p Policy) (Entry, bool, error)
+	// Settle ends an attempt granted by Reserve and returns the updated record.
+	Settle(ctx context.Context, key string, p Policy, outcome Outcome) (Entry, error)
+	// Reset forgets the failures of key, it reports whether there were any.
+	Reset(ctx context.Context, key string) (bool, error)
+	// List returns the records of all keys with recent failures.
+	List(ctx context.Context) ([]Entry, error)
+}
+
+// reserve counts a pending failed attempt at now on e. It reports false
+// while e is locked out, delayed or has MaxInFlight pending attempts.
+func (p Policy) reserve(e Entry, now time.Time) (Entry, bool) {
+	if e.Locked(now) || e.Delayed(now) || (p.MaxInFlight > 0 && e.InFlight >= p.MaxInFlight) {
+		return e, false
+	}
+
+	lockExpired := !e.LockedUntil.IsZero() && !e.Locked(now)
+	if lockExpired || now.Sub(e.LastFailure) > p.Window {
+		e = Entry{Key: e.Key, InFlight: e.InFlight}
+	}
+
+	e.Failures++
+	e.InFlight++
+	e.LastFailure = now
+	return p.throttle(e), true
+}
+
+// settle ends a pending attempt on e.
+func (p Policy) settle(e Entry, outcome Outcome) Entry {
+	if e.InFlight > 0 {
+		e.InFlight--
+	}
+
+	switch outcome {
+	case Succeeded:
+		// LastFailure keeps the record of the other pending attempts alive
+		return Entry{Key: e.Key, InFlight: e.InFlight, LastFailure: e.LastFailure}
+	case Refunded:
+		if e.Failures > 0 {
+			e.Failures--
+		}
+		// the refunded failure may be the one that locked or delayed the key
+		e.LockedUntil, e.NextAttempt = time.Time{}, time.Time{}
+		return p.throttle(e)
+	}
+	return e
+}
+
+// throttle sets the lockout and the delay earned by the failures of e,
+// counted from its last failure. A running lockout is kept.
+func (p Policy) throttle(e Entry) Entry {
+	if p.MaxFailures > 0 && e.Failures >= p.MaxFailures {
+		if e.LockedUntil.IsZero() {
+			e.LockedUntil = e.LastFailure.Add(p.Duration)
+		}
+		e.NextAttempt = e.LockedUntil
+		return e
+	}
+
+	if e.Failures > 1 && p.BaseDelay > 0 {
+		delay := p.BaseDelay << (e.Failures - 2)
+		if delay > p.MaxDelay || delay <= 0 {
+			delay = p.MaxDelay
+		}
+		e.NextAttempt = e.LastFailure.Add(delay)
+	}
+	return e
+}
+
+// empty reports whether e has nothing left to remember.
+func (e Entry) empty() bool {
+	return e.Failures == 0 && e.InFlight == 0
+}
+
+// expiresAt returns when the record stops mattering.
+func (p Policy) expiresAt(e Entry) time.Time {
+	exp := e.LastFailure.Add(p.Window)
+	if e.LockedUntil.After(exp) {
+		exp = e.LockedUntil
+	}
+	return exp
+}
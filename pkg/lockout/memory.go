@@ -0,0 +1,127 @@
+package lockout
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// purgeInterval is how often records whose failures are forgotten are dropped.
+const purgeInterval = time.Minute
+
+type record struct {
+	entry     Entry
+	expiresAt time.Time
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	records   map[string]record
+	lastPurge time.Time
+}
+
+// NewInMemory returns a Store that lives in the gateway process.
+// Records are lost on restart and are not shared between replicas,
+// so it is meant for tests and single-instance deployments.
+func NewInMemory() Store {
+	return &memoryStore{
+		records:   make(map[string]record),
+		lastPurge: time.Now(),
+	}
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[key]
+	if !ok || !r.expiresAt.After(time.Now()) {
+		return Entry{Key: key}, nil
+	}
+	return r.entry, nil
+}
+
+func (s *memoryStore) Reserve(_ context.Context, key string, p Policy) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.purge(now)
+
+	e, ok := p.reserve(s.entry(key, now), now)
+	if ok {
+		s.put(e, p)
+	}
+	return e, ok, nil
+}
+
+func (s *memoryStore) Settle(_ context.Context, key string, p Policy, outcome Outcome) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.purge(now)
+
+	e := p.settle(s.entry(key, now), outcome)
+	s.put(e, p)
+	return e, nil
+}
+
+// entry returns the record of key, an empty one once it has expired.
+// s.mu must be held.
+func (s *memoryStore) entry(key string, now time.Time) Entry {
+	r, ok := s.records[key]
+	if !ok || !r.expiresAt.After(now) {
+		return Entry{Key: key}
+	}
+	return r.entry
+}
+
+// put stores e, or forgets its key when it is empty. s.mu must be held.
+func (s *memoryStore) put(e Entry, p Policy) {
+	if e.empty() {
+		delete(s.records, e.Key)
+		return
+	}
+	s.records[e.Key] = record{entry: e, expiresAt: p.expiresAt(e)}
+}
+
+func (s *memoryStore) Reset(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[key]
+	delete(s.records, key)
+	return ok && r.expiresAt.After(time.Now()), nil
+}
+
+func (s *memoryStore) List(_ context.Context) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]Entry, 0, len(s.records))
+	for _, r := range s.records {
+		if r.expiresAt.After(now) && r.entry.Failures > 0 {
+			entries = append(entries, r.entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// purge drops records whose failures are forgotten anyway, at most once per
+// purgeInterval. Until then entry and List skip them.
+func (s *memoryStore) purge(now time.Time) {
+	if now.Sub(s.lastPurge) < purgeInterval {
+		return
+	}
+	s.lastPurge = now
+
+	for key, r := range s.records {
+		if !r.expiresAt.After(now) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package lockout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix = "lockout:"
+	// maxTxRetries bounds the optimistic transactions of Reserve and Settle under contention.
+	maxTxRetries = 10
+)
+
+type redisStore struct {
+	rdb *redis.Client
+}
+
+// NewRedis returns a Store backed by Redis, shared by all gateway replicas.
+func NewRedis(rdb *redis.Client) Store {
+	return &redisStore{rdb: rdb}
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) (Entry, error) {
+	return get(ctx, s.rdb, key)
+}
+
+func (s *redisStore) Reserve(ctx context.Context, key string, p Policy) (Entry, bool, error) {
+	var reserved bool
+	e, err := s.update(ctx, key, p, func(cur Entry, now time.Time) (Entry, bool) {
+		var e Entry
+		e, reserved = p.reserve(cur, now)
+		return e, reserved
+	})
+	return e, reserved, err
+}
+
+func (s *redisStore) Settle(ctx context.Context, key string, p Policy, outcome Outcome) (Entry, error) {
+	return s.update(ctx, key, p, func(cur Entry, _ time.Time) (Entry, bool) {
+		return p.settle(cur, outcome), true
+	})
+}
+
+// update applies change to the record of key in an optimistic transaction,
+// retried when another gateway changes the record meanwhile. The record is
+// written only when change reports true.
+func (s *redisStore) update(ctx context.Context, key string, p Policy, change func(cur Entry, now time.Time) (Entry, bool)) (Entry, error) {
+	var e Entry
+
+	apply := func(tx *redis.Tx) error {
+		cur, err := get(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var write bool
+		e, write = change(cur, now)
+		e.Key = key
+		if !write {
+			return nil
+		}
+
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if ttl := p.expiresAt(e).Sub(now); e.empty() || ttl <= 0 {
+				pipe.Del(ctx, keyPrefix+key)
+			} else {
+				pipe.Set(ctx, keyPrefix+key, data, ttl)
+			}
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < maxTxRetries; i++ {
+		err := s.rdb.Watch(ctx, apply, keyPrefix+key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return e, err
+		}
+	}
+	return Entry{}, redis.TxFailedErr
+}
+
+func (s *redisStore) Reset(ctx context.Context, key string) (bool, error) {
+	n, err := s.rdb.Del(ctx, keyPrefix+key).Result()
+	return n > 0, err
+}
+
+func (s *redisStore) List(ctx context.Context) ([]Entry, error) {
+	var entries []Entry
+
+	iter := s.rdb.Scan(ctx, 0, keyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		e, err := get(ctx, s.rdb, strings.TrimPrefix(iter.Val(), keyPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if e.Failures > 0 {
+			entries = append(entries, e)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+func get(ctx context.Context, rdb redis.Cmdable, key string) (Entry, error) {
+	data, err := rdb.Get(ctx, keyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Entry{Key: key}, nil
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Entry{}, err
+	}
+	return e, nil
+}
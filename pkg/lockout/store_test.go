@@ -0,0 +1,124 @@
+package lockout_test
+
+import (
+	"api_gateway/pkg/lockout"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+var policy = lockout.Policy{
+	MaxFailures: 3,
+	Duration:    time.Minute,
+	Window:      time.Minute,
+}
+
+// stores returns a store of every backend.
+func stores(t *testing.T) map[string]lockout.Store {
+	t.Helper()
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	return map[string]lockout.Store{
+		lockout.BackendMemory: lockout.NewInMemory(),
+		lockout.BackendRedis:  lockout.NewRedis(rdb),
+	}
+}
+
+func reserve(t *testing.T, store lockout.Store, key string) lockout.Entry {
+	t.Helper()
+
+	e, ok, err := store.Reserve(context.Background(), key, policy)
+	if err != nil || !ok {
+		t.Fatalf("reserve %s: granted %v, %v", key, ok, err)
+	}
+	return e
+}
+
+func settle(t *testing.T, store lockout.Store, key string, outcome lockout.Outcome) lockout.Entry {
+	t.Helper()
+
+	e, err := store.Settle(context.Background(), key, policy, outcome)
+	if err != nil {
+		t.Fatalf("settle %s: %v", key, err)
+	}
+	return e
+}
+
+func TestStoreLocksOut(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < policy.MaxFailures; i++ {
+				reserve(t, store, "alice")
+				settle(t, store, "alice", lockout.Failed)
+			}
+
+			e, ok, err := store.Reserve(context.Background(), "alice", policy)
+			if err != nil || ok {
+				t.Fatalf("reserve of a locked key: granted %v, %v", ok, err)
+			}
+			if !e.Locked(time.Now()) || e.Failures != policy.MaxFailures {
+				t.Fatalf("entry = %+v, want locked with %d failures", e, policy.MaxFailures)
+			}
+
+			if reset, err := store.Reset(context.Background(), "alice"); err != nil || !reset {
+				t.Fatalf("reset: %v, %v", reset, err)
+			}
+			if e, err := store.Get(context.Background(), "alice"); err != nil || e.Failures != 0 {
+				t.Fatalf("entry after reset = %+v, %v", e, err)
+			}
+		})
+	}
+}
+
+func TestStoreListsKeysWithFailures(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			reserve(t, store, "bob")
+			settle(t, store, "bob", lockout.Failed)
+
+			// a success forgets the failures, the other pending attempt keeps a record
+			reserve(t, store, "carol")
+			reserve(t, store, "carol")
+			if e := settle(t, store, "carol", lockout.Succeeded); e.Failures != 0 || e.InFlight != 1 {
+				t.Fatalf("entry = %+v, want no failures and one attempt in flight", e)
+			}
+
+			entries, err := store.List(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Key != "bob" || entries[0].Failures != 1 {
+				t.Fatalf("entries = %+v, want bob with 1 failure", entries)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreForgetsExpiredFailures(t *testing.T) {
+	store := lockout.NewInMemory()
+	short := lockout.Policy{MaxFailures: 2, Duration: time.Millisecond, Window: time.Millisecond}
+
+	for i := 0; i < short.MaxFailures; i++ {
+		if _, ok, err := store.Reserve(context.Background(), "dave", short); err != nil || !ok {
+			t.Fatalf("reserve: granted %v, %v", ok, err)
+		}
+		if _, err := store.Settle(context.Background(), "dave", short, lockout.Failed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// expired records are skipped before the next purge drops them
+	if entries, err := store.List(context.Background()); err != nil || len(entries) != 0 {
+		t.Fatalf("entries = %+v, %v, want none", entries, err)
+	}
+	e, ok, err := store.Reserve(context.Background(), "dave", short)
+	if err != nil || !ok || e.Failures != 1 {
+		t.Fatalf("reserve after expiry: %+v, granted %v, %v, want a fresh record", e, ok, err)
+	}
+}
@@ -1,8 +1,11 @@
 package logger
 
 import (
+	"api_gateway/pkg/requestid"
+	"context"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -19,6 +22,8 @@ var (
 	Error = zap.Error
 	// Bool ...
 	Bool = zap.Bool
+	// Duration ...
+	Duration = zap.Duration
 
 	// Any ...
 	Any = zap.Any
@@ -58,6 +63,11 @@ func New(level string, namespace string) Logger {
 	return &logger
 }
 
+// FromZap wraps a zap logger, e.g. one observing the lines in tests.
+func FromZap(z *zap.Logger) Logger {
+	return &loggerImpl{zap: z}
+}
+
 func (l *loggerImpl) Debug(msg string, fields ...Field) {
 	l.zap.Debug(msg, fields...)
 }
@@ -103,6 +113,22 @@ func WithFields(l Logger, fields ...Field) Logger {
 	}
 }
 
+// WithContext returns l with the request ID and the trace carried by ctx
+// attached to every line.
+func WithContext(ctx context.Context, l Logger) Logger {
+	var fields []Field
+	if id := requestid.FromContext(ctx); id != "" {
+		fields = append(fields, String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields, String("trace_id", sc.TraceID().String()), String("span_id", sc.SpanID().String()))
+	}
+	if len(fields) == 0 {
+		return l
+	}
+	return WithFields(l, fields...)
+}
+
 // Cleanup ...
 func Cleanup(l Logger) error {
 	switch v := l.(type) {
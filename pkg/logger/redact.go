@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Redacted replaces the values of secret fields in logged messages.
+const Redacted = "[REDACTED]"
+
+// Proto logs a proto message as JSON with its secrets masked, see Redact.
+func Proto(key string, m proto.Message) Field {
+	if m == nil {
+		return zap.Skip()
+	}
+
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(Redact(m))
+	if err != nil {
+		return zap.NamedError(key, err)
+	}
+	// protojson randomizes its whitespace
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return zap.NamedError(key, err)
+	}
+	return zap.Reflect(key, json.RawMessage(compact.Bytes()))
+}
+
+// Redact returns a copy of m with the secret fields masked: passwords, OTPs,
+// tokens and secrets, however they are cased, in nested messages, lists and
+// maps too. String fields become Redacted, other secret fields are cleared.
+func Redact(m proto.Message) proto.Message {
+	if m == nil {
+		return nil
+	}
+	clone := proto.Clone(m)
+	redactMessage(clone.ProtoReflect())
+	return clone
+}
+
+func redactMessage(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if secret(string(fd.Name())) {
+			if fd.Kind() == protoreflect.StringKind && !fd.IsList() && !fd.IsMap() {
+				m.Set(fd, protoreflect.ValueOfString(Redacted))
+			} else {
+				m.Clear(fd)
+			}
+			return true
+		}
+
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				redactMessage(list.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				redactMessage(v.Message())
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			redactMessage(v.Message())
+		}
+		return true
+	})
+}
+
+// secret reports whether a field name, e.g. user_password, NewPassword, otp
+// or refresh_token, names a secret.
+func secret(name string) bool {
+	name = strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	return name == "otp" ||
+		strings.Contains(name, "password") ||
+		strings.Contains(name, "token") ||
+		strings.Contains(name, "secret")
+}
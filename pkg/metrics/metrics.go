@@ -0,0 +1,167 @@
+// Package metrics holds the Prometheus collectors of the gateway. The HTTP
+// middleware, the gRPC clients and the auth, rate limit and login code
+// update them, Handler serves them in the Prometheus text format.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes the names of the metrics of the gateway.
+const Namespace = "api_gateway"
+
+// UnmatchedRoute is the route label of the requests matching no route, so
+// scans of random paths do not create a series per path.
+const UnmatchedRoute = "unmatched"
+
+// OtherMethod is the method label of the requests with a method outside
+// knownMethods, which clients may choose freely.
+const OtherMethod = "OTHER"
+
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Reasons of the rejected tokens.
+const (
+	AuthFailureInvalidToken = "invalid_token"
+	AuthFailureRevokedToken = "revoked_token"
+	AuthFailureForbidden    = "forbidden"
+)
+
+// Results of the login attempts.
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure" // rejected by the service, counts towards the lockout
+	LoginLocked  = "locked"  // rejected by the lockout before reaching the service
+	LoginError   = "error"   // the service could not answer
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests, by route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	httpInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests being handled, by route template.",
+	}, []string{"method", "route"})
+
+	grpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "grpc_client_requests_total",
+		Help:      "gRPC calls to the services, by method and status code. Retries of a call count once.",
+	}, []string{"service", "method", "code"})
+
+	grpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "grpc_client_request_duration_seconds",
+		Help:      "Latency of the gRPC calls to the services including retries, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "method"})
+
+	authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "auth_failures_total",
+		Help:      "Requests rejected for their access or refresh token, by reason.",
+	}, []string{"reason"})
+
+	rateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by the rate limiter, by route group.",
+	}, []string{"group"})
+
+	loginAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "login_attempts_total",
+		Help:      "Login attempts, by account type and result.",
+	}, []string{"account", "result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		httpInFlight,
+		grpcRequests,
+		grpcDuration,
+		authFailures,
+		rateLimitRejections,
+		loginAttempts,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// HTTPRequestStarted counts a request in flight until the returned func is
+// called with its status code.
+func HTTPRequestStarted(method, route string) (done func(status int)) {
+	if route == "" {
+		route = UnmatchedRoute
+	}
+	if !knownMethods[method] {
+		method = OtherMethod
+	}
+	start := time.Now()
+	inFlight := httpInFlight.WithLabelValues(method, route)
+	inFlight.Inc()
+
+	return func(status int) {
+		inFlight.Dec()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	}
+}
+
+// GRPCRequest records a finished gRPC call, code is the name of its status code.
+func GRPCRequest(service, method, code string, d time.Duration) {
+	grpcRequests.WithLabelValues(service, method, code).Inc()
+	grpcDuration.WithLabelValues(service, method).Observe(d.Seconds())
+}
+
+// AuthFailure counts a token rejected for reason, one of the AuthFailure* constants.
+func AuthFailure(reason string) {
+	authFailures.WithLabelValues(reason).Inc()
+}
+
+// RateLimitRejection counts a request rejected by the limiter of group.
+func RateLimitRejection(group string) {
+	rateLimitRejections.WithLabelValues(group).Inc()
+}
+
+// LoginAttempt counts a login of account with result, one of the Login* constants.
+func LoginAttempt(account, result string) {
+	loginAttempts.WithLabelValues(account, result).Inc()
+}
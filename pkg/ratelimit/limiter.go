@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// BackendMemory keeps buckets in the gateway process.
+	BackendMemory = "memory"
+	// BackendRedis keeps buckets in Redis, so limits hold across gateway replicas.
+	BackendRedis = "redis"
+
+	// KeyByIP limits each client address.
+	KeyByIP = "ip"
+	// KeyByUser limits each authenticated user, anonymous requests by address.
+	KeyByUser = "user"
+	// KeyByAPIKey limits each allow-listed API key, other requests by address.
+	KeyByAPIKey = "api_key"
+)
+
+// Limit is a token bucket holding up to Burst tokens and refilled at Rate tokens per second.
+// A zero Limit means unlimited.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited reports whether the limit disables rate limiting.
+func (l Limit) Unlimited() bool {
+	return l.Burst <= 0 || l.Rate <= 0
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long to wait for the next token when not allowed.
+	RetryAfter time.Duration
+	// ResetAfter is how long it takes the bucket to fill up again.
+	ResetAfter time.Duration
+}
+
+// Limiter takes tokens from per-key buckets.
+type Limiter interface {
+	Allow(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// ParseLimit parses "<requests>/<period>", e.g. "10/1m" allows bursts of
+// 10 requests refilled over a minute. "" and "off" mean unlimited.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "off" {
+		return Limit{}, nil
+	}
+
+	n, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q: expected <requests>/<period>", s)
+	}
+	burst, err := strconv.Atoi(n)
+	if err != nil || burst <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: requests must be a positive number", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: period must be a positive duration", s)
+	}
+
+	return Limit{
+		Rate:  float64(burst) / d.Seconds(),
+		Burst: burst,
+	}, nil
+}
+
+// ValidKeyBy reports whether keyBy is one of KeyByIP, KeyByUser and KeyByAPIKey.
+func ValidKeyBy(keyBy string) bool {
+	switch keyBy {
+	case KeyByIP, KeyByUser, KeyByAPIKey:
+		return true
+	}
+	return false
+}
+
+// Settings are the rate limits of the gateway, see the RATE_LIMIT_* variables.
+type Settings struct {
+	Backend      string   // BackendMemory or BackendRedis
+	Auth         string   // limit of the public routes in ParseLimit format
+	AuthKey      string   // KeyBy* of the public routes
+	API          string   // limit of the secured routes in ParseLimit format
+	APIKey       string   // KeyBy* of the secured routes
+	APIKeyHeader string   // header carrying the API key of KeyByAPIKey
+	APIKeys      []string // HashAPIKey digests of the API keys limited on their own
+}
+
+// Validate checks the settings before the gateway starts.
+func (s Settings) Validate() error {
+	var errs []error
+	if s.Backend != BackendMemory && s.Backend != BackendRedis {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_BACKEND must be memory or redis, got %q", s.Backend))
+	}
+	errs = append(errs, validateGroup("RATE_LIMIT_AUTH", s.Auth, s.AuthKey)...)
+	errs = append(errs, validateGroup("RATE_LIMIT_API", s.API, s.APIKey)...)
+	if s.AuthKey == KeyByAPIKey || s.APIKey == KeyByAPIKey {
+		if s.APIKeyHeader == "" {
+			errs = append(errs, errors.New("RATE_LIMIT_API_KEY_HEADER is required in api_key mode"))
+		}
+		if len(s.APIKeys) == 0 {
+			errs = append(errs, errors.New("RATE_LIMIT_API_KEYS is required in api_key mode, every request would be limited by address"))
+		}
+	}
+	for i, hash := range s.APIKeys {
+		// never echo the entry, it may be a raw key pasted by mistake
+		if !ValidAPIKeyHash(hash) {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_API_KEYS entry %d is not a lowercase hex SHA-256 digest", i+1))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateGroup(name, spec, keyBy string) []error {
+	var errs []error
+	if _, err := ParseLimit(spec); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+	if !ValidKeyBy(keyBy) {
+		errs = append(errs, fmt.Errorf("%s_KEY must be ip, user or api_key, got %q", name, keyBy))
+	}
+	return errs
+}
+
+// HashAPIKey returns the hex SHA-256 digest of an API key, the form API keys
+// are allow-listed in, so the configuration never holds the keys themselves.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidAPIKeyHash reports whether hash is a digest returned by HashAPIKey.
+func ValidAPIKeyHash(hash string) bool {
+	if len(hash) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil && hash == strings.ToLower(hash)
+}
+
+// result builds a Result from the tokens left in a bucket after a take attempt.
+func result(l Limit, allowed bool, tokens float64) Result {
+	r := Result{
+		Allowed:    allowed,
+		Limit:      l.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: seconds((float64(l.Burst) - tokens) / l.Rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / l.Rate)
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
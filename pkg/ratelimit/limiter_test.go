@@ -0,0 +1,30 @@
+package ratelimit
+
+import "testing"
+
+func TestSettingsValidate(t *testing.T) {
+	valid := Settings{Backend: BackendMemory, Auth: "10/1m", AuthKey: KeyByIP, API: "off", APIKey: KeyByUser}
+
+	for _, tc := range []struct {
+		name   string
+		change func(s *Settings)
+		valid  bool
+	}{
+		{"defaults", func(*Settings) {}, true},
+		{"unknown backend", func(s *Settings) { s.Backend = "memcached" }, false},
+		{"invalid limit", func(s *Settings) { s.API = "300" }, false},
+		{"unknown key", func(s *Settings) { s.AuthKey = "session" }, false},
+		{"api keys", func(s *Settings) {
+			s.AuthKey, s.APIKeyHeader, s.APIKeys = KeyByAPIKey, "X-API-Key", []string{HashAPIKey("key")}
+		}, true},
+		{"api key mode without keys", func(s *Settings) { s.AuthKey, s.APIKeyHeader = KeyByAPIKey, "X-API-Key" }, false},
+		{"api key mode without header", func(s *Settings) { s.APIKey, s.APIKeys = KeyByAPIKey, []string{HashAPIKey("key")} }, false},
+		{"raw api key", func(s *Settings) { s.AuthKey, s.APIKeyHeader, s.APIKeys = KeyByAPIKey, "X-API-Key", []string{"key"} }, false},
+	} {
+		s := valid
+		tc.change(&s)
+		if err := s.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%s: error = %v, want valid %v", tc.name, err, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package rbac
+
+import (
+	"api_gateway/config"
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed default_policy.yaml
+var defaultPolicy []byte
+
+// Rule grants roles an action. Rules with Method and Path guard a route,
+// rules with only an Action are capabilities checked by handlers.
+type Rule struct {
+	Method string   `yaml:"method,omitempty" json:"method,omitempty"`
+	Path   string   `yaml:"path,omitempty" json:"path,omitempty"`
+	Action string   `yaml:"action" json:"action"`
+	Roles  []string `yaml:"roles" json:"roles"`
+}
+
+// Policy is the set of access rules enforced by the gateway.
+type Policy struct {
+	Rules []Rule `yaml:"rules" json:"rules"`
+
+	routes  map[string]Rule
+	actions map[string]map[string]bool
+}
+
+// Default returns the policy embedded into the binary.
+func Default() *Policy {
+	p, err := Parse(defaultPolicy)
+	if err != nil {
+		panic(fmt.Sprintf("rbac: invalid default policy: %v", err))
+	}
+	return p
+}
+
+// Load reads a policy from a YAML file. An empty path means the default policy.
+func Load(path string) (*Policy, error) {
+	if path == "" {
+		return Default(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("rbac: read policy file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse builds a policy from YAML and validates its rules.
+func Parse(data []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("rbac: parse policy: %w", err)
+	}
+
+	p.routes = make(map[string]Rule)
+	p.actions = make(map[string]map[string]bool)
+
+	for i, r := range p.Rules {
+		r.Method = strings.ToUpper(r.Method)
+		p.Rules[i] = r
+
+		if r.Action == "" {
+			return nil, fmt.Errorf("rbac: rule %d has no action", i)
+		}
+		if (r.Method == "") != (r.Path == "") {
+			return nil, fmt.Errorf("rbac: rule %d (%s) must set both method and path or neither", i, r.Action)
+		}
+		for _, role := range r.Roles {
+			if !knownRole(role) {
+				return nil, fmt.Errorf("rbac: rule %d (%s) has unknown role %q", i, r.Action, role)
+			}
+		}
+
+		if r.Path != "" {
+			key := routeKey(r.Method, r.Path)
+			if _, ok := p.routes[key]; ok {
+				return nil, fmt.Errorf("rbac: duplicate rule for %s", key)
+			}
+			p.routes[key] = r
+		}
+
+		if p.actions[r.Action] == nil {
+			p.actions[r.Action] = make(map[string]bool)
+		}
+		for _, role := range r.Roles {
+			p.actions[r.Action][role] = true
+		}
+	}
+
+	return p, nil
+}
+
+// Rule returns the rule guarding the route template path (as in gin's FullPath).
+func (p *Policy) Rule(method, path string) (Rule, bool) {
+	r, ok := p.routes[routeKey(method, path)]
+	return r, ok
+}
+
+// Allowed reports whether role may call the route. Routes without a rule are denied.
+func (p *Policy) Allowed(role, method, path string) bool {
+	r, ok := p.Rule(method, path)
+	if !ok {
+		return false
+	}
+	for _, allowed := range r.Roles {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether any rule grants role the action.
+func (p *Policy) Can(role, action string) bool {
+	return p.actions[action][role]
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+func knownRole(role string) bool {
+	switch role {
+	case config.SUPERADMIN_ROLE, config.ADMIN_ROLE, config.USER_ROLE:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+// Package requestid carries the ID correlating an HTTP request with the logs
+// and the gRPC calls made while handling it.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+const (
+	// Header is the HTTP header carrying the request ID.
+	Header = "X-Request-ID"
+	// MetadataKey is the gRPC metadata key carrying the request ID to the services.
+	MetadataKey = "x-request-id"
+
+	// maxLength bounds the IDs accepted from clients.
+	maxLength = 128
+)
+
+type contextKey struct{}
+
+// New returns a random version 4 UUID.
+func New() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Valid reports whether an ID sent by a client may be reused. It must be
+// short and made of letters, digits and "-_.:" only, so it is safe to log
+// and to send as metadata.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, or "".
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,86 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type cutoff struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+type memoryStore struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time
+	users  map[string]cutoff
+}
+
+// NewInMemory returns a Store that lives in the gateway process.
+// Revocations are lost on restart and are not shared between replicas,
+// so it is meant for tests and single-instance deployments.
+func NewInMemory() Store {
+	return &memoryStore{
+		tokens: make(map[string]time.Time),
+		users:  make(map[string]cutoff),
+	}
+}
+
+func (s *memoryStore) Revoke(_ context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.purge(now)
+
+	if exp, ok := s.tokens[tokenID]; ok && exp.After(now) {
+		return false, nil
+	}
+	s.tokens[tokenID] = expiresAt
+	return true, nil
+}
+
+func (s *memoryStore) IsRevoked(_ context.Context, tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exp, ok := s.tokens[tokenID]
+	return ok && exp.After(time.Now()), nil
+}
+
+func (s *memoryStore) RevokeIssuedBefore(_ context.Context, userID string, t time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[userID] = cutoff{
+		issuedBefore: t,
+		expiresAt:    time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (s *memoryStore) IssuedBefore(_ context.Context, userID string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.users[userID]
+	if !ok || !c.expiresAt.After(time.Now()) {
+		return time.Time{}, nil
+	}
+	return c.issuedBefore, nil
+}
+
+// purge drops entries of tokens that have expired anyway.
+func (s *memoryStore) purge(now time.Time) {
+	for id, exp := range s.tokens {
+		if !exp.After(now) {
+			delete(s.tokens, id)
+		}
+	}
+	for id, c := range s.users {
+		if !c.expiresAt.After(now) {
+			delete(s.users, id)
+		}
+	}
+}
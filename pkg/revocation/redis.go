@@ -0,0 +1,65 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	tokenKeyPrefix = "revoked:token:"
+	userKeyPrefix  = "revoked:user:"
+)
+
+type redisStore struct {
+	rdb *redis.Client
+}
+
+// NewRedis returns a Store backed by Redis, shared by all gateway replicas.
+func NewRedis(rdb *redis.Client) Store {
+	return &redisStore{rdb: rdb}
+}
+
+func (s *redisStore) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		// an expired token is rejected anyway, there is nothing to remember
+		ttl = time.Second
+	}
+	return s.rdb.SetNX(ctx, tokenKeyPrefix+tokenID, 1, ttl).Result()
+}
+
+func (s *redisStore) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	n, err := s.rdb.Exists(ctx, tokenKeyPrefix+tokenID).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *redisStore) RevokeIssuedBefore(ctx context.Context, userID string, t time.Time, ttl time.Duration) error {
+	return s.rdb.Set(ctx, userKeyPrefix+userID, t.UTC().Format(time.RFC3339Nano), ttl).Err()
+}
+
+func (s *redisStore) IssuedBefore(ctx context.Context, userID string) (time.Time, error) {
+	v, err := s.rdb.Get(ctx, userKeyPrefix+userID).Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, nil
+	}
+	// cutoffs of older gateways are Unix seconds
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(sec, 0), nil
+}
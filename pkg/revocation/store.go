@@ -0,0 +1,28 @@
+package revocation
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// BackendMemory keeps revocations in the gateway process.
+	BackendMemory = "memory"
+	// BackendRedis keeps revocations in Redis, shared by all gateway replicas.
+	BackendRedis = "redis"
+)
+
+// Store keeps identifiers of tokens that must no longer be accepted.
+type Store interface {
+	// Revoke marks tokenID as revoked until expiresAt. It reports false when the
+	// token had already been revoked, so a refresh token can be consumed only once.
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error)
+	// IsRevoked reports whether tokenID has been revoked.
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+
+	// RevokeIssuedBefore revokes every token of userID issued before t.
+	// The cutoff is kept for ttl, after which those tokens have expired anyway.
+	RevokeIssuedBefore(ctx context.Context, userID string, t time.Time, ttl time.Duration) error
+	// IssuedBefore returns the cutoff set by RevokeIssuedBefore, or zero time if there is none.
+	IssuedBefore(ctx context.Context, userID string) (time.Time, error)
+}
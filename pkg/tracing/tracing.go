@@ -0,0 +1,108 @@
+// Package tracing sets up the OpenTelemetry tracer provider of the gateway.
+// The gin engine and the gRPC clients use the global provider and propagator
+// installed by Setup.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// Exporters of the spans.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// ServiceName is the service.name of the spans of the gateway.
+const ServiceName = "api_gateway"
+
+// Settings configure the tracer provider.
+type Settings struct {
+	Exporter     string  // none, stdout, otlp
+	OTLPEndpoint string  // host:port of the OTLP gRPC collector
+	OTLPInsecure bool    // plaintext connection to the collector
+	SampleRatio  float64 // share of the traces started by the gateway that are sampled
+	Environment  string  // deployment.environment of the spans
+}
+
+// Validate checks the settings before the gateway starts.
+func (s Settings) Validate() error {
+	var errs []error
+	switch s.Exporter {
+	case ExporterNone, ExporterStdout:
+	case ExporterOTLP:
+		if _, _, err := net.SplitHostPort(s.OTLPEndpoint); err != nil {
+			errs = append(errs, fmt.Errorf("TRACING_OTLP_ENDPOINT: %w", err))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER must be none, stdout or otlp, got %q", s.Exporter))
+	}
+	if s.SampleRatio < 0 || s.SampleRatio > 1 {
+		errs = append(errs, errors.New("TRACING_SAMPLE_RATIO must be between 0 and 1"))
+	}
+	return errors.Join(errs...)
+}
+
+// Setup installs the W3C trace context propagator and, unless the exporter is
+// none, a tracer provider exporting the spans. The returned shutdown flushes
+// the spans not exported yet.
+//
+// With the none exporter no span is recorded, but the trace context of the
+// callers is still passed on to the services.
+func Setup(ctx context.Context, s Settings) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(Propagator())
+
+	var exporter sdktrace.SpanExporter
+	switch s.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	case ExporterOTLP:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(s.OTLPEndpoint)}
+		if s.OTLPInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", s.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s trace exporter: %w", s.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+		semconv.DeploymentEnvironment(s.Environment),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// follow the sampling decision of the caller, sample SampleRatio of the new traces
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(s.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Propagator propagates the W3C trace context and baggage.
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}